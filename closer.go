@@ -0,0 +1,112 @@
+package zerolog
+
+import (
+	"io"
+	"sync"
+
+	"gitlab.com/tozd/go/errors"
+)
+
+// Closer is returned from New and flushes and closes all sinks
+// and context loggers created by New.
+type Closer struct {
+	mu       sync.Mutex
+	closers  []io.Closer
+	contexts map[*contextWriter]struct{}
+}
+
+func newCloser() *Closer {
+	return &Closer{
+		mu:       sync.Mutex{},
+		closers:  nil,
+		contexts: map[*contextWriter]struct{}{},
+	}
+}
+
+// add registers a sink to close.
+func (c *Closer) add(closer io.Closer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closers = append(c.closers, closer)
+}
+
+// addContext registers a context logger's writer which has not yet been closed.
+func (c *Closer) addContext(w *contextWriter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.contexts[w] = struct{}{}
+}
+
+// removeContext unregisters a context logger's writer once it is closed.
+func (c *Closer) removeContext(w *contextWriter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.contexts, w)
+}
+
+// Close flushes and closes all sinks and context loggers.
+//
+// Context loggers which have not yet been closed (their close function has not
+// yet been called) have any buffered log entries flushed (triggered) first, so
+// that those log entries are not lost. Then all sinks are closed.
+//
+// All errors are aggregated and returned together. Calling Close again is
+// a no-op, as is calling it on a nil Closer.
+//
+// Close implements io.Closer interface for Closer.
+func (c *Closer) Close() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	contexts := c.contexts
+	closers := c.closers
+	c.contexts = map[*contextWriter]struct{}{}
+	c.closers = nil
+	c.mu.Unlock()
+
+	var errE errors.E
+	for w := range contexts {
+		err := w.Trigger()
+		if err != nil {
+			errE = errors.Join(errE, errors.WithMessage(err, "cannot flush context logger"))
+		}
+		err = w.Close()
+		if err != nil {
+			errE = errors.Join(errE, errors.WithMessage(err, "cannot close context logger"))
+		}
+	}
+	for _, closer := range closers {
+		err := closer.Close()
+		if err != nil {
+			errE = errors.Join(errE, errors.WithMessage(err, "cannot close logging sink"))
+		}
+	}
+	if errE != nil {
+		return errE
+	}
+	return nil
+}
+
+// Reopen reopens all sinks which support reopening (e.g., files).
+//
+// See FileWriter's Reopen for details.
+func (c *Closer) Reopen() errors.E {
+	c.mu.Lock()
+	closers := c.closers
+	c.mu.Unlock()
+
+	var errE errors.E
+	for _, closer := range closers {
+		if r, ok := closer.(interface{ Reopen() errors.E }); ok {
+			errE = errors.Join(errE, r.Reopen())
+		}
+	}
+	return errE
+}
+
+var _ io.Closer = (*Closer)(nil)
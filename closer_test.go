@@ -0,0 +1,94 @@
+package zerolog_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	z "gitlab.com/tozd/go/zerolog"
+)
+
+func TestCloser(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "log")
+	other := filepath.Join(dir, "other.log")
+
+	config := z.LoggingConfig{
+		Logger:      zerolog.Nop(),
+		WithContext: nil,
+		Logging: z.Logging{
+			Console: z.Console{
+				Type:        "disable",
+				Level:       zerolog.DebugLevel,
+				Target:      "stdout",
+				Output:      nil,
+				ErrorOutput: nil,
+			},
+			File: z.File{ //nolint:exhaustruct
+				Level: zerolog.DebugLevel,
+				Path:  p,
+			},
+			Files: []z.File{
+				{Path: other, Level: zerolog.DebugLevel}, //nolint:exhaustruct
+			},
+			Main: z.Main{
+				Level:        zerolog.Disabled,
+				Slog:         false,
+				StdlogLevel:  nil,
+				StdlogCaller: false,
+			},
+			Context: z.Context{
+				Level:             zerolog.DebugLevel,
+				ConditionalLevel:  zerolog.DebugLevel,
+				TriggerLevel:      zerolog.ErrorLevel,
+				MaxEntries:        0,
+				MaxBytes:          0,
+				Summary:           false,
+				SummaryLevel:      zerolog.InfoLevel,
+				TriggerOnDeadline: false,
+				TriggerOnDone:     nil,
+				Triggers:          nil,
+			},
+		},
+	}
+	closer, errE := z.New(&config)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	t.Cleanup(func() {
+		// We might double close but we do not care.
+		_ = closer.Close()
+	})
+
+	pendingCtx, closePending, _ := config.WithContext(context.Background())
+	t.Cleanup(closePending)
+	closedCtx, closeClosed, _ := config.WithContext(context.Background())
+
+	zerolog.Ctx(pendingCtx).Debug().Msg("pending")
+	zerolog.Ctx(closedCtx).Debug().Msg("closed")
+	// Buffered entries of a closed context logger are discarded.
+	closeClosed()
+
+	err := closer.Close()
+	require.NoError(t, err, "% -+#.1v", err)
+
+	// Buffered entries of a pending context logger are flushed on close to all files.
+	for _, path := range []string{p, other} {
+		content, err := os.ReadFile(filepath.Clean(path))
+		require.NoError(t, err)
+		expectLog("debug", `"pending"`)(t, string(content))
+	}
+
+	// Closing again is a no-op.
+	err = closer.Close()
+	assert.NoError(t, err, "% -+#.1v", err)
+}
+
+func TestCloserNil(t *testing.T) {
+	var closer *z.Closer
+	err := closer.Close()
+	assert.NoError(t, err, "% -+#.1v", err)
+}
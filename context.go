@@ -0,0 +1,306 @@
+package zerolog
+
+import (
+	"bytes"
+	"context"
+	"sync"
+
+	"github.com/rs/zerolog"
+	"gitlab.com/tozd/go/errors"
+)
+
+// bufferedEntry is a log entry buffered by contextWriter.
+type bufferedEntry struct {
+	level zerolog.Level
+	data  []byte
+}
+
+// contextWriter is used by context loggers. It buffers log entries at or below
+// ConditionalLevel until a log entry at or above TriggerLevel is written
+// (or it is triggered explicitly), at which point buffered log entries are
+// flushed and all further log entries pass through to Writer.
+//
+// The buffer is bounded by MaxEntries and MaxBytes (there is no limit if 0).
+// When a limit is reached, the oldest buffered log entries are dropped so that
+// the buffer keeps the most recent log entries. When flushing, a marker log entry
+// with the number of dropped log entries is written first, at the highest level
+// of dropped log entries.
+//
+// Besides by level, it can be triggered by any of Triggers returning true
+// for a written log entry.
+//
+// If closed before being triggered and SummaryLevel is not disabled, a log entry at
+// SummaryLevel with the number of discarded log entries per level is written.
+//
+// The marker and summary log entries are written with fields of the context logger
+// most recently set with setLogger, so that they can be attributed (e.g., to a request).
+//
+// It is similar to zerolog.TriggerLevelWriter, but with a bounded buffer.
+type contextWriter struct {
+	Writer           zerolog.LevelWriter
+	ConditionalLevel zerolog.Level
+	TriggerLevel     zerolog.Level
+	MaxEntries       int
+	MaxBytes         int
+	SummaryLevel     zerolog.Level
+	Triggers         []TriggerFunc
+
+	mu           sync.Mutex
+	entries      []bufferedEntry
+	size         int
+	dropped      int
+	droppedLevel zerolog.Level
+	counts       map[zerolog.Level]int
+	written      map[zerolog.Level]int
+	triggered    bool
+	logger       *zerolog.Logger
+}
+
+// contextLoggerWriterKey is the context key under which the context logger's writer
+// is stored for withContextLogger.
+type contextLoggerWriterKey struct{}
+
+// withContextLogger stores the logger in the context as its context logger.
+//
+// If the context has a context logger's writer, the writer uses the logger's fields
+// for log entries it writes itself.
+func withContextLogger(ctx context.Context, logger zerolog.Logger) context.Context {
+	ctx = logger.WithContext(ctx)
+	if w, ok := ctx.Value(contextLoggerWriterKey{}).(*contextWriter); ok {
+		w.setLogger(zerolog.Ctx(ctx))
+	}
+	return ctx
+}
+
+// passThroughLogger returns a copy of the context logger of the context which
+// writes log entries directly, without buffering them and without triggering
+// the context logger.
+func passThroughLogger(ctx context.Context) zerolog.Logger {
+	logger := *zerolog.Ctx(ctx)
+	if w, ok := ctx.Value(contextLoggerWriterKey{}).(*contextWriter); ok {
+		logger = logger.Output(passThroughWriter{w: w})
+	}
+	return logger
+}
+
+// passThroughWriter writes log entries to contextWriter's Writer directly.
+type passThroughWriter struct {
+	w *contextWriter
+}
+
+// Write implements io.Writer interface for passThroughWriter.
+//
+// The log entry is passed through without a level.
+func (w passThroughWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(zerolog.NoLevel, p)
+}
+
+// WriteLevel implements zerolog.LevelWriter interface for passThroughWriter.
+func (w passThroughWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	w.w.mu.Lock()
+	defer w.w.mu.Unlock()
+
+	return w.w.Writer.WriteLevel(level, p) //nolint:wrapcheck
+}
+
+// setLogger sets the logger whose fields are used for log entries written by the writer itself.
+func (w *contextWriter) setLogger(logger *zerolog.Logger) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.logger = logger
+}
+
+// Write implements io.Writer interface for contextWriter.
+//
+// The log entry is passed through without a level.
+func (w *contextWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(zerolog.NoLevel, p)
+}
+
+// WriteLevel implements zerolog.LevelWriter interface for contextWriter.
+func (w *contextWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// At first trigger level or above log entry (or a log entry for which any
+	// of trigger functions returns true), we flush the buffer and change the
+	// state to triggered.
+	if !w.triggered && (level >= w.TriggerLevel || w.matchTriggers(level, p)) {
+		err := w.trigger()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// Unless triggered, we buffer everything at and below ConditionalLevel.
+	if !w.triggered && level <= w.ConditionalLevel {
+		w.buffer(level, p)
+		return len(p), nil
+	}
+
+	// Anything above ConditionalLevel is always passed through.
+	// Once triggered, everything is passed through.
+	return w.Writer.WriteLevel(level, p) //nolint:wrapcheck
+}
+
+// matchTriggers returns true if any of trigger functions returns true for the log entry.
+//
+// It expects the caller to hold the lock.
+func (w *contextWriter) matchTriggers(level zerolog.Level, p []byte) bool {
+	if len(w.Triggers) == 0 {
+		return false
+	}
+
+	if w.written == nil {
+		w.written = map[zerolog.Level]int{}
+	}
+	w.written[level]++
+
+	// If the log entry cannot be parsed, trigger functions see no fields.
+	fields, _ := parseEntry(p)
+	entry := TriggerEntry{
+		Level:  level,
+		Data:   p,
+		Counts: w.written,
+		fields: fields,
+	}
+	for _, trigger := range w.Triggers {
+		if trigger(entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// buffer appends a copy of the log entry to the buffer, dropping
+// the oldest log entries if needed to stay within limits.
+//
+// It expects the caller to hold the lock.
+func (w *contextWriter) buffer(level zerolog.Level, p []byte) {
+	if w.counts == nil {
+		w.counts = map[zerolog.Level]int{}
+	}
+	w.counts[level]++
+
+	if w.MaxBytes > 0 && len(p) > w.MaxBytes {
+		// The log entry can never fit.
+		w.drop(level)
+		return
+	}
+
+	w.entries = append(w.entries, bufferedEntry{level: level, data: bytes.Clone(p)})
+	w.size += len(p)
+
+	for (w.MaxEntries > 0 && len(w.entries) > w.MaxEntries) || (w.MaxBytes > 0 && w.size > w.MaxBytes) {
+		oldest := w.entries[0]
+		// We clear the entry so that its data can be garbage collected.
+		w.entries[0] = bufferedEntry{} //nolint:exhaustruct
+		w.entries = w.entries[1:]
+		w.size -= len(oldest.data)
+		w.drop(oldest.level)
+	}
+}
+
+// drop records a dropped log entry at the level.
+//
+// It expects the caller to hold the lock.
+func (w *contextWriter) drop(level zerolog.Level) {
+	if w.dropped == 0 || level > w.droppedLevel {
+		w.droppedLevel = level
+	}
+	w.dropped++
+}
+
+// reset discards all buffered log entries.
+//
+// It expects the caller to hold the lock.
+func (w *contextWriter) reset() {
+	w.entries = nil
+	w.size = 0
+	w.dropped = 0
+	w.counts = nil
+}
+
+// trigger flushes buffered log entries and changes the state to triggered.
+//
+// It expects the caller to hold the lock.
+func (w *contextWriter) trigger() error {
+	if w.triggered {
+		return nil
+	}
+	w.triggered = true
+
+	entries := w.entries
+	dropped := w.dropped
+	droppedLevel := w.droppedLevel
+	w.reset()
+
+	if dropped > 0 {
+		err := w.log(droppedLevel, func(e *zerolog.Event) {
+			e.Int("dropped", dropped).Msg("buffered log entries dropped")
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, entry := range entries {
+		_, err := w.Writer.WriteLevel(entry.level, entry.data)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return nil
+}
+
+// log writes a log entry at the level, constructed by the provided function, to Writer.
+//
+// It expects the caller to hold the lock.
+func (w *contextWriter) log(level zerolog.Level, f func(e *zerolog.Event)) error {
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf).With().Timestamp().Logger()
+	if w.logger != nil {
+		// The context logger already adds the timestamp. We log at the level
+		// even if the context logger's level is higher.
+		logger = w.logger.Output(&buf).Level(zerolog.TraceLevel)
+	}
+	f(logger.WithLevel(level))
+	_, err := w.Writer.WriteLevel(level, buf.Bytes())
+	return errors.WithStack(err)
+}
+
+// Trigger flushes buffered log entries and passes through all further log entries.
+func (w *contextWriter) Trigger() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.trigger()
+}
+
+// Close discards buffered log entries.
+//
+// If there were any and SummaryLevel is not disabled, it writes a summary
+// log entry with the number of discarded log entries per level.
+func (w *contextWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	counts := w.counts
+	w.reset()
+
+	if len(counts) == 0 || w.SummaryLevel == zerolog.Disabled {
+		return nil
+	}
+
+	return w.log(w.SummaryLevel, func(e *zerolog.Event) {
+		discarded := zerolog.Dict()
+		for level := zerolog.TraceLevel; level < zerolog.NoLevel; level++ {
+			if counts[level] > 0 {
+				discarded.Int(level.String(), counts[level])
+			}
+		}
+		e.Dict("discarded", discarded).Msg("buffered log entries discarded")
+	})
+}
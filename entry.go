@@ -0,0 +1,66 @@
+package zerolog
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"gitlab.com/tozd/go/errors"
+)
+
+// entryField is a top-level field of a JSON log entry.
+type entryField struct {
+	Key   string
+	Value json.RawMessage
+}
+
+// parseEntry parses top-level fields of a JSON log entry, preserving their order.
+func parseEntry(p []byte) ([]entryField, errors.E) {
+	decoder := json.NewDecoder(bytes.NewReader(p))
+	decoder.UseNumber()
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if token != json.Delim('{') {
+		return nil, errors.New("log entry is not a JSON object")
+	}
+	fields := []entryField{}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		key, ok := token.(string)
+		if !ok {
+			return nil, errors.New("invalid log entry field name")
+		}
+		var value json.RawMessage
+		err = decoder.Decode(&value)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		fields = append(fields, entryField{Key: key, Value: value})
+	}
+	return fields, nil
+}
+
+// fieldString returns the field's value as a string. Strings are unquoted
+// while other values are returned as JSON.
+func fieldString(value json.RawMessage) string {
+	if len(value) > 0 && value[0] == '"' {
+		var s string
+		err := json.Unmarshal(value, &s)
+		if err == nil {
+			return s
+		}
+	}
+	return string(value)
+}
+
+// stackFrame is a frame of error's stack trace as marshaled into JSON
+// by gitlab.com/tozd/go/errors.
+type stackFrame struct {
+	Name string `json:"name"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
@@ -0,0 +1,582 @@
+package zerolog
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+	"gitlab.com/tozd/go/errors"
+)
+
+const compressedSuffix = ".gz"
+
+// Layouts of timestamped suffixes of rotated files, by rotation period.
+//
+//nolint:gochecknoglobals
+var rotateLayouts = map[string]string{
+	"hourly": "2006-01-02T15",
+	"daily":  "2006-01-02",
+}
+
+// backupRegexp matches suffixes of both numbered and timestamped rotated files.
+//
+//nolint:gochecknoglobals
+var backupRegexp = regexp.MustCompile(`^\.(?:\d+|\d{4}-\d{2}-\d{2}(?:T\d{2})?(?:\.\d+)?)(?:\.gz)?$`)
+
+// FileWriter appends log entries to a file and rotates the file once
+// it reaches the configured maximum size or when the configured period
+// (hourly or daily) changes.
+//
+// Without a period, rotated files are renamed to numbered backups: the most
+// recent backup has suffix ".1", the one before it ".2", and so on.
+// With a period, rotated files are renamed to backups with a suffix of the
+// period's timestamp (in UTC), e.g., ".2006-01-02" for daily rotation. If the file
+// is rotated multiple times in the same period (because of its size), a counter
+// is appended to the suffix, e.g., ".2006-01-02.1".
+//
+// Rotated files can be compressed with gzip and removed once they are
+// older than the configured maximum age. Both is done in the background.
+//
+// The file can also be rotated externally (e.g., by logrotate) in which case
+// Reopen should be called after the file has been renamed. Optionally,
+// FileWriter calls Reopen itself when the process receives SIGHUP.
+//
+// It is safe for concurrent use.
+type FileWriter struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	maxAge     time.Duration
+	rotate     string
+	compress   bool
+	mode       os.FileMode
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	period time.Time
+
+	// Background compression and removal of rotated files.
+	background sync.WaitGroup
+
+	// Reopening the file on SIGHUP.
+	signals     chan os.Signal
+	signalsDone chan struct{}
+}
+
+// NewFileWriter opens (or creates) the file at the path configured in file
+// and returns a FileWriter appending to it.
+//
+// The file (and its rotated files) are created with the configured mode,
+// or with mode 0o600 if the mode is not configured.
+func NewFileWriter(file File) (*FileWriter, errors.E) {
+	if file.MaxSize < 0 {
+		errE := errors.New("invalid logging file max size")
+		errors.Details(errE)["value"] = file.MaxSize
+		return nil, errE
+	}
+	if file.MaxBackups < 0 {
+		errE := errors.New("invalid logging file max backups")
+		errors.Details(errE)["value"] = file.MaxBackups
+		return nil, errE
+	}
+	if file.MaxAge < 0 {
+		errE := errors.New("invalid logging file max age")
+		errors.Details(errE)["value"] = file.MaxAge.String()
+		return nil, errE
+	}
+	if _, ok := rotateLayouts[file.Rotate]; file.Rotate != "" && !ok {
+		errE := errors.New("invalid logging file rotate period")
+		errors.Details(errE)["value"] = file.Rotate
+		return nil, errE
+	}
+	if file.Mode&^os.ModePerm != 0 {
+		errE := errors.New("invalid logging file mode")
+		errors.Details(errE)["value"] = file.Mode.String()
+		return nil, errE
+	}
+	mode := file.Mode
+	if mode == 0 {
+		mode = fileMode
+	}
+
+	w := &FileWriter{
+		path:        file.Path,
+		maxSize:     file.MaxSize,
+		maxBackups:  file.MaxBackups,
+		maxAge:      file.MaxAge,
+		rotate:      file.Rotate,
+		compress:    file.Compress,
+		mode:        mode,
+		mu:          sync.Mutex{},
+		file:        nil,
+		size:        0,
+		period:      time.Time{},
+		background:  sync.WaitGroup{},
+		signals:     nil,
+		signalsDone: nil,
+	}
+
+	f, size, period, errE := w.open()
+	if errE != nil {
+		return nil, errE
+	}
+	w.file = f
+	w.size = size
+	w.period = period
+
+	if file.Reopen {
+		w.signals = make(chan os.Signal, 1)
+		w.signalsDone = make(chan struct{})
+		signal.Notify(w.signals, syscall.SIGHUP)
+		go w.handleSignals(w.signals)
+	}
+
+	return w, nil
+}
+
+// handleSignals reopens the file on every received signal
+// until the signals channel is closed.
+func (w *FileWriter) handleSignals(signals <-chan os.Signal) {
+	defer close(w.signalsDone)
+
+	for range signals {
+		errE := w.Reopen()
+		if errE != nil {
+			reportError(errE)
+		}
+	}
+}
+
+// Reopen opens (or creates) the file at the path again and closes the previous file.
+//
+// Use it after the file has been renamed by an external tool (e.g., logrotate)
+// so that log entries are appended to the new file at the path and not to the
+// renamed file. Concurrent writes wait for Reopen to finish.
+//
+// If opening the file fails, log entries continue to be appended to the previous file.
+func (w *FileWriter) Reopen() errors.E {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return errors.WithStack(os.ErrClosed)
+	}
+
+	f, size, period, errE := w.open()
+	if errE != nil {
+		return errE
+	}
+
+	return w.replace(f, size, period)
+}
+
+// truncate returns the start of the rotation period t belongs to.
+func (w *FileWriter) truncate(t time.Time) time.Time {
+	t = t.UTC()
+	switch w.rotate {
+	case "hourly":
+		return t.Truncate(time.Hour)
+	case "daily":
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Time{}
+	}
+}
+
+// open opens the file at the path and returns it together with its current size
+// and the rotation period it belongs to.
+func (w *FileWriter) open() (*os.File, int64, time.Time, errors.E) {
+	f, err := os.OpenFile(w.path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, w.mode)
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot open logging file")
+		errors.Details(errE)["path"] = w.path
+		return nil, 0, time.Time{}, errE
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		errE := errors.WithMessage(err, "cannot stat logging file")
+		errors.Details(errE)["path"] = w.path
+		return nil, 0, time.Time{}, errE
+	}
+	if info.Size() > 0 {
+		// An existing file belongs to the period in which it was last written to.
+		return f, info.Size(), w.truncate(info.ModTime()), nil
+	}
+	return f, 0, w.truncate(time.Now()), nil
+}
+
+// replace replaces the current file with the opened file f and closes the current file.
+//
+// It expects the caller to hold the lock.
+func (w *FileWriter) replace(f *os.File, size int64, period time.Time) errors.E {
+	previous := w.file
+	w.file = f
+	w.size = size
+	w.period = period
+
+	err := previous.Close()
+	if err != nil {
+		return errors.WithMessage(err, "cannot close logging file")
+	}
+	return nil
+}
+
+// Write implements io.Writer interface for FileWriter.
+//
+// Each call is expected to contain one whole log entry. If the rotation period
+// has changed or if appending the entry would make the file exceed the maximum
+// size, the file is rotated first.
+func (w *FileWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return 0, errors.WithStack(os.ErrClosed)
+	}
+
+	var rotateErr errors.E
+	if w.rotate != "" {
+		period := w.truncate(time.Now())
+		if w.size == 0 {
+			// An empty file does not have to be rotated, it just starts the new period.
+			w.period = period
+		} else if !period.Equal(w.period) {
+			rotateErr = w.rotateFile()
+		}
+	}
+	// We never rotate an empty file so that an entry larger than
+	// the maximum size does not cause a rotation on every write.
+	if rotateErr == nil && w.maxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		rotateErr = w.rotateFile()
+	}
+
+	// Even if rotation failed, we still write the entry to the current file.
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	if err != nil {
+		return n, errors.Join(rotateErr, err)
+	}
+	if rotateErr != nil {
+		return n, rotateErr
+	}
+	return n, nil
+}
+
+// backupPath returns the path of the numbered backup.
+func (w *FileWriter) backupPath(n int) string {
+	return w.path + "." + strconv.Itoa(n)
+}
+
+// existingPath returns p or its compressed variant, whichever exists.
+// It returns an empty string if neither exists.
+func existingPath(p string) (string, errors.E) {
+	for _, name := range []string{p, p + compressedSuffix} {
+		_, err := os.Stat(name)
+		if err == nil {
+			return name, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			errE := errors.WithMessage(err, "cannot stat logging file backup")
+			errors.Details(errE)["path"] = name
+			return "", errE
+		}
+	}
+	return "", nil
+}
+
+// rotateFile renames the current file to a backup, opens a new file at
+// the path, and closes the current file. Compression and removal of old
+// backups is then started in the background.
+//
+// If rotation fails, log entries continue to be appended to the current file.
+//
+// It expects the caller to hold the lock.
+func (w *FileWriter) rotateFile() errors.E {
+	// Background work operates on backups so we wait for it
+	// to finish before we change them again.
+	w.background.Wait()
+
+	var backup string
+	var errE errors.E
+	if w.rotate != "" {
+		backup, errE = w.timestampedBackup()
+	} else {
+		backup, errE = w.shift()
+	}
+	if errE != nil {
+		return errE
+	}
+	err := os.Rename(w.path, backup)
+	if err != nil {
+		return errors.WithMessage(err, "cannot rename logging file")
+	}
+
+	f, size, period, errE := w.open()
+	if errE != nil {
+		// We continue to append to the current file, so we move it back to the path.
+		err := os.Rename(backup, w.path)
+		if err != nil {
+			errE = errors.Join(errE, errors.WithMessage(err, "cannot rename logging file back"))
+		}
+		return errE
+	}
+
+	errE = w.replace(f, size, period)
+
+	w.background.Add(1)
+	go func() {
+		defer w.background.Done()
+		w.maintain(backup)
+	}()
+
+	return errE
+}
+
+// timestampedBackup returns the first unused backup path
+// for the current rotation period.
+func (w *FileWriter) timestampedBackup() (string, errors.E) {
+	base := w.path + "." + w.period.Format(rotateLayouts[w.rotate])
+	backup := base
+	for n := 1; ; n++ {
+		existing, errE := existingPath(backup)
+		if errE != nil {
+			return "", errE
+		}
+		if existing == "" {
+			return backup, nil
+		}
+		backup = base + "." + strconv.Itoa(n)
+	}
+}
+
+// shift shifts existing numbered backups by one, removing those over the maximum
+// number of backups, and returns the path of the first backup.
+func (w *FileWriter) shift() (string, errors.E) {
+	// We find existing backups.
+	existing := []string{}
+	for {
+		p, errE := existingPath(w.backupPath(len(existing) + 1))
+		if errE != nil {
+			return "", errE
+		}
+		if p == "" {
+			break
+		}
+		existing = append(existing, p)
+	}
+
+	for i := len(existing) - 1; i >= 0; i-- {
+		n := i + 1
+		if w.maxBackups > 0 && n >= w.maxBackups {
+			err := os.Remove(existing[i])
+			if err != nil {
+				return "", errors.WithMessage(err, "cannot remove logging file backup")
+			}
+			continue
+		}
+		// We preserve the compressed suffix, if any.
+		suffix := existing[i][len(w.backupPath(n)):]
+		err := os.Rename(existing[i], w.backupPath(n+1)+suffix)
+		if err != nil {
+			return "", errors.WithMessage(err, "cannot rename logging file backup")
+		}
+	}
+
+	return w.backupPath(1), nil
+}
+
+// maintain compresses the backup (if configured) and removes old backups.
+//
+// It runs in the background so it reports errors through zerolog.ErrorHandler.
+func (w *FileWriter) maintain(backup string) {
+	if w.compress {
+		errE := compressFile(backup, w.mode)
+		if errE != nil {
+			reportError(errE)
+		}
+	}
+	errE := w.removeOld()
+	if errE != nil {
+		reportError(errE)
+	}
+}
+
+// reportError reports an error which happened in the background.
+func reportError(err error) {
+	if zerolog.ErrorHandler != nil {
+		zerolog.ErrorHandler(err)
+	} else {
+		fmt.Fprintf(os.Stderr, "zerolog: %s\n", err.Error())
+	}
+}
+
+// compressFile compresses the file at p with gzip into a file with
+// the compressed suffix and removes the file at p.
+//
+// The compressed file is created with mode and keeps
+// the modification time of the original file.
+func compressFile(p string, mode os.FileMode) errors.E {
+	in, err := os.Open(p) //nolint:gosec
+	if err != nil {
+		return errors.WithMessage(err, "cannot open logging file backup")
+	}
+	defer in.Close() //nolint:errcheck
+
+	info, err := in.Stat()
+	if err != nil {
+		return errors.WithMessage(err, "cannot stat logging file backup")
+	}
+
+	tmp := p + compressedSuffix + ".tmp"
+	errE := writeCompressed(in, tmp, mode)
+	if errE != nil {
+		_ = os.Remove(tmp)
+		return errE
+	}
+	err = os.Chtimes(tmp, info.ModTime(), info.ModTime())
+	if err != nil {
+		_ = os.Remove(tmp)
+		return errors.WithMessage(err, "cannot set compressed logging file backup times")
+	}
+	err = os.Rename(tmp, p+compressedSuffix)
+	if err != nil {
+		_ = os.Remove(tmp)
+		return errors.WithMessage(err, "cannot rename compressed logging file backup")
+	}
+	err = os.Remove(p)
+	if err != nil {
+		return errors.WithMessage(err, "cannot remove logging file backup")
+	}
+	return nil
+}
+
+// writeCompressed writes contents of in compressed with gzip into a new file at p.
+func writeCompressed(in io.Reader, p string, mode os.FileMode) errors.E {
+	out, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode) //nolint:gosec
+	if err != nil {
+		return errors.WithMessage(err, "cannot create compressed logging file backup")
+	}
+	defer out.Close() //nolint:errcheck
+
+	gz := gzip.NewWriter(out)
+	_, err = io.Copy(gz, in)
+	if err != nil {
+		return errors.WithMessage(err, "cannot compress logging file backup")
+	}
+	err = gz.Close()
+	if err != nil {
+		return errors.WithMessage(err, "cannot compress logging file backup")
+	}
+	err = out.Close()
+	if err != nil {
+		return errors.WithMessage(err, "cannot close compressed logging file backup")
+	}
+	return nil
+}
+
+// removeOld removes backups older than the maximum age and, for timestamped
+// backups, the oldest backups over the maximum number of backups.
+func (w *FileWriter) removeOld() errors.E {
+	if w.maxAge == 0 && (w.maxBackups == 0 || w.rotate == "") {
+		return nil
+	}
+
+	dir := filepath.Dir(w.path)
+	base := filepath.Base(w.path)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot read logging file directory")
+		errors.Details(errE)["path"] = dir
+		return errE
+	}
+
+	type backup struct {
+		path    string
+		modTime time.Time
+	}
+	backups := []backup{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || len(name) <= len(base) || name[:len(base)] != base || !backupRegexp.MatchString(name[len(base):]) {
+			continue
+		}
+		info, err := entry.Info()
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return errors.WithMessage(err, "cannot stat logging file backup")
+		}
+		backups = append(backups, backup{filepath.Join(dir, name), info.ModTime()})
+	}
+
+	// Newest first.
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].modTime.After(backups[j].modTime)
+	})
+
+	var errE errors.E
+	cutoff := time.Now().Add(-w.maxAge)
+	for i, b := range backups {
+		if (w.maxAge > 0 && b.modTime.Before(cutoff)) || (w.rotate != "" && w.maxBackups > 0 && i >= w.maxBackups) {
+			err := os.Remove(b.path)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				errE = errors.Join(errE, errors.WithMessage(err, "cannot remove logging file backup"))
+			}
+		}
+	}
+	return errE
+}
+
+// Close implements io.Closer interface for FileWriter.
+//
+// It waits for any background compression and removal of rotated files to finish.
+//
+// It is a no-op when called on a nil FileWriter.
+func (w *FileWriter) Close() error {
+	if w == nil {
+		return nil
+	}
+
+	w.stopSignals()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.background.Wait()
+
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.file.Close()
+	w.file = nil
+	return errors.WithStack(err)
+}
+
+// stopSignals stops reopening the file on SIGHUP, if it was enabled,
+// and waits for any reopening in progress to finish.
+func (w *FileWriter) stopSignals() {
+	w.mu.Lock()
+	signals := w.signals
+	w.signals = nil
+	w.mu.Unlock()
+
+	if signals == nil {
+		return
+	}
+
+	signal.Stop(signals)
+	close(signals)
+	<-w.signalsDone
+}
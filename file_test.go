@@ -0,0 +1,491 @@
+package zerolog_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	z "gitlab.com/tozd/go/zerolog"
+)
+
+func TestFileWriterRotation(t *testing.T) {
+	for k, tt := range []struct {
+		MaxBackups int
+		Expected   []string
+	}{
+		{0, []string{"log", "log.1", "log.2", "log.3", "log.4"}},
+		{2, []string{"log", "log.1", "log.2"}},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			dir := t.TempDir()
+			p := filepath.Join(dir, "log")
+
+			w, errE := z.NewFileWriter(z.File{ //nolint:exhaustruct
+				Path:       p,
+				MaxSize:    10,
+				MaxBackups: tt.MaxBackups,
+			})
+			require.NoError(t, errE, "% -+#.1v", errE)
+			t.Cleanup(func() {
+				// We might double close but we do not care.
+				_ = w.Close()
+			})
+
+			for i := range 5 {
+				_, err := fmt.Fprintf(w, "line %d\n", i)
+				require.NoError(t, err)
+			}
+			require.NoError(t, w.Close())
+
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			names := []string{}
+			for _, entry := range entries {
+				names = append(names, entry.Name())
+			}
+			assert.Equal(t, tt.Expected, names)
+
+			// The most recent entry is in the file itself and older entries are in backups in order.
+			for i, name := range tt.Expected {
+				content, err := os.ReadFile(filepath.Join(dir, name))
+				require.NoError(t, err)
+				assert.Equal(t, fmt.Sprintf("line %d\n", 4-i), string(content))
+			}
+		})
+	}
+}
+
+func TestFileWriterTimeRotation(t *testing.T) {
+	old := time.Now().UTC().Add(-48 * time.Hour)
+
+	for _, tt := range []struct {
+		Rotate string
+		Suffix string
+	}{
+		{"daily", old.Format("2006-01-02")},
+		{"hourly", old.Format("2006-01-02T15")},
+	} {
+		t.Run(tt.Rotate, func(t *testing.T) {
+			dir := t.TempDir()
+			p := filepath.Join(dir, "log")
+
+			// A file from an earlier period.
+			require.NoError(t, os.WriteFile(p, []byte("old\n"), 0o600))
+			require.NoError(t, os.Chtimes(p, old, old))
+
+			w, errE := z.NewFileWriter(z.File{ //nolint:exhaustruct
+				Path:   p,
+				Rotate: tt.Rotate,
+			})
+			require.NoError(t, errE, "% -+#.1v", errE)
+			t.Cleanup(func() {
+				// We might double close but we do not care.
+				_ = w.Close()
+			})
+
+			_, err := w.Write([]byte("new1\n"))
+			require.NoError(t, err)
+			// The second write is in the same period and does not rotate.
+			_, err = w.Write([]byte("new2\n"))
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+
+			content, err := os.ReadFile(filepath.Clean(p + "." + tt.Suffix))
+			require.NoError(t, err)
+			assert.Equal(t, "old\n", string(content))
+			content, err = os.ReadFile(filepath.Clean(p))
+			require.NoError(t, err)
+			assert.Equal(t, "new1\nnew2\n", string(content))
+		})
+	}
+}
+
+func TestFileWriterTimeAndSizeRotation(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "log")
+
+	w, errE := z.NewFileWriter(z.File{ //nolint:exhaustruct
+		Path:    p,
+		MaxSize: 10,
+		Rotate:  "daily",
+	})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	t.Cleanup(func() {
+		// We might double close but we do not care.
+		_ = w.Close()
+	})
+
+	for i := range 3 {
+		_, err := fmt.Fprintf(w, "line %d\n", i)
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	// Rotations in the same period get a counter appended to the timestamped suffix.
+	suffix := time.Now().UTC().Format("2006-01-02")
+	for name, expected := range map[string]string{
+		"log":                  "line 2\n",
+		"log." + suffix:        "line 0\n",
+		"log." + suffix + ".1": "line 1\n",
+	} {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(content), name)
+	}
+}
+
+func TestFileWriterCompress(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "log")
+
+	w, errE := z.NewFileWriter(z.File{ //nolint:exhaustruct
+		Path:     p,
+		MaxSize:  10,
+		Compress: true,
+	})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	t.Cleanup(func() {
+		// We might double close but we do not care.
+		_ = w.Close()
+	})
+
+	for i := range 3 {
+		_, err := fmt.Fprintf(w, "line %d\n", i)
+		require.NoError(t, err)
+	}
+	// Close waits for compression in the background to finish.
+	require.NoError(t, w.Close())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"log", "log.1.gz", "log.2.gz"}, names)
+
+	for i, name := range names[1:] {
+		f, err := os.Open(filepath.Join(dir, name))
+		require.NoError(t, err)
+		r, err := gzip.NewReader(f)
+		require.NoError(t, err)
+		content, err := io.ReadAll(r)
+		require.NoError(t, err)
+		_ = f.Close()
+		assert.Equal(t, fmt.Sprintf("line %d\n", 1-i), string(content))
+	}
+}
+
+func TestFileWriterRetention(t *testing.T) {
+	for k, tt := range []struct {
+		MaxAge     time.Duration
+		MaxBackups int
+		Expected   []string
+	}{
+		{0, 0, []string{"log", "log.2020-01-01", "log.2020-01-02.gz", "log.2020-01-03", "log.other"}},
+		{24 * time.Hour, 0, []string{"log", "log.2020-01-03", "log.other"}},
+		{0, 2, []string{"log", "log.2020-01-03", "log.other"}},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			dir := t.TempDir()
+			p := filepath.Join(dir, "log")
+
+			for name, modTime := range map[string]time.Time{
+				"log.2020-01-01":    time.Now().Add(-72 * time.Hour),
+				"log.2020-01-02.gz": time.Now().Add(-48 * time.Hour),
+				"log.2020-01-03":    time.Now().Add(-1 * time.Hour),
+				// Not a backup so it is never removed.
+				"log.other": time.Now().Add(-72 * time.Hour),
+			} {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("old\n"), 0o600))
+				require.NoError(t, os.Chtimes(filepath.Join(dir, name), modTime, modTime))
+			}
+
+			w, errE := z.NewFileWriter(z.File{ //nolint:exhaustruct
+				Path:       p,
+				MaxSize:    5,
+				MaxAge:     tt.MaxAge,
+				MaxBackups: tt.MaxBackups,
+				Rotate:     "daily",
+			})
+			require.NoError(t, errE, "% -+#.1v", errE)
+			t.Cleanup(func() {
+				// We might double close but we do not care.
+				_ = w.Close()
+			})
+
+			_, err := w.Write([]byte("line\n"))
+			require.NoError(t, err)
+			_, err = w.Write([]byte("line\n"))
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			names := []string{}
+			for _, entry := range entries {
+				names = append(names, entry.Name())
+			}
+			suffix := time.Now().UTC().Format("2006-01-02")
+			expected := []string{}
+			for _, name := range tt.Expected {
+				expected = append(expected, name)
+				if name == "log" {
+					// The file rotated now is the newest backup and is always kept.
+					expected = append(expected, "log."+suffix)
+				}
+			}
+			assert.ElementsMatch(t, expected, names)
+		})
+	}
+}
+
+func TestFileWriterReopen(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "log")
+
+	w, errE := z.NewFileWriter(z.File{ //nolint:exhaustruct
+		Path: p,
+	})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	t.Cleanup(func() {
+		// We might double close but we do not care.
+		_ = w.Close()
+	})
+
+	_, err := w.Write([]byte("before\n"))
+	require.NoError(t, err)
+	// Simulate external rotation.
+	require.NoError(t, os.Rename(p, p+".rotated"))
+	_, err = w.Write([]byte("renamed\n"))
+	require.NoError(t, err)
+	errE = w.Reopen()
+	require.NoError(t, errE, "% -+#.1v", errE)
+	_, err = w.Write([]byte("after\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	content, err := os.ReadFile(filepath.Clean(p + ".rotated"))
+	require.NoError(t, err)
+	assert.Equal(t, "before\nrenamed\n", string(content))
+	content, err = os.ReadFile(filepath.Clean(p))
+	require.NoError(t, err)
+	assert.Equal(t, "after\n", string(content))
+
+	errE = w.Reopen()
+	assert.ErrorIs(t, errE, os.ErrClosed)
+}
+
+func TestFileWriterReopenFailure(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "log")
+
+	w, errE := z.NewFileWriter(z.File{ //nolint:exhaustruct
+		Path: p,
+	})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	t.Cleanup(func() {
+		// We might double close but we do not care.
+		_ = w.Close()
+	})
+
+	_, err := w.Write([]byte("before\n"))
+	require.NoError(t, err)
+	// Simulate external rotation after which the file cannot be opened.
+	require.NoError(t, os.Rename(p, p+".rotated"))
+	require.NoError(t, os.Mkdir(p, 0o700))
+	errE = w.Reopen()
+	assert.Error(t, errE)
+	// Log entries are still appended to the previous file.
+	_, err = w.Write([]byte("failed\n"))
+	require.NoError(t, err)
+	// Reopening can recover once the file can be opened again.
+	require.NoError(t, os.Remove(p))
+	errE = w.Reopen()
+	require.NoError(t, errE, "% -+#.1v", errE)
+	_, err = w.Write([]byte("after\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	content, err := os.ReadFile(filepath.Clean(p + ".rotated"))
+	require.NoError(t, err)
+	assert.Equal(t, "before\nfailed\n", string(content))
+	content, err = os.ReadFile(filepath.Clean(p))
+	require.NoError(t, err)
+	assert.Equal(t, "after\n", string(content))
+}
+
+func TestFileWriterReopenOnSignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("SIGHUP is not supported on Windows")
+	}
+
+	dir := t.TempDir()
+	p := filepath.Join(dir, "log")
+
+	w, errE := z.NewFileWriter(z.File{ //nolint:exhaustruct
+		Path:   p,
+		Reopen: true,
+	})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	t.Cleanup(func() {
+		// We might double close but we do not care.
+		_ = w.Close()
+	})
+
+	_, err := w.Write([]byte("before\n"))
+	require.NoError(t, err)
+	// Simulate external rotation.
+	require.NoError(t, os.Rename(p, p+".rotated"))
+
+	process, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+	require.NoError(t, process.Signal(syscall.SIGHUP))
+
+	// Reopening happens asynchronously.
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(p)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, err = w.Write([]byte("after\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	content, err := os.ReadFile(filepath.Clean(p + ".rotated"))
+	require.NoError(t, err)
+	assert.Equal(t, "before\n", string(content))
+	content, err = os.ReadFile(filepath.Clean(p))
+	require.NoError(t, err)
+	assert.Equal(t, "after\n", string(content))
+}
+
+func TestFileWriterLargeEntry(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "log")
+
+	w, errE := z.NewFileWriter(z.File{ //nolint:exhaustruct
+		Path:    p,
+		MaxSize: 5,
+	})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	t.Cleanup(func() {
+		// We might double close but we do not care.
+		_ = w.Close()
+	})
+
+	// An entry larger than the maximum size is written into an empty file without rotation.
+	_, err := w.Write([]byte("larger than max size\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	_, err = os.Stat(p + ".1")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	content, err := os.ReadFile(filepath.Clean(p))
+	require.NoError(t, err)
+	assert.Equal(t, "larger than max size\n", string(content))
+}
+
+func TestFileWriterConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "log")
+
+	config := z.LoggingConfig{
+		Logger:      zerolog.Nop(),
+		WithContext: nil,
+		Logging: z.Logging{
+			Console: z.Console{
+				Type:        "disable",
+				Level:       zerolog.DebugLevel,
+				Target:      "stdout",
+				Output:      nil,
+				ErrorOutput: nil,
+			},
+			File: z.File{
+				Level:      zerolog.DebugLevel,
+				Path:       p,
+				MaxSize:    1024,
+				MaxBackups: 0,
+			},
+			Main: z.Main{
+				Level:        zerolog.DebugLevel,
+				Slog:         false,
+				StdlogLevel:  nil,
+				StdlogCaller: false,
+			},
+			Context: z.Context{
+				Level:             zerolog.DebugLevel,
+				ConditionalLevel:  zerolog.DebugLevel,
+				TriggerLevel:      zerolog.DebugLevel,
+				MaxEntries:        0,
+				MaxBytes:          0,
+				Summary:           false,
+				SummaryLevel:      zerolog.InfoLevel,
+				TriggerOnDeadline: false,
+				TriggerOnDone:     nil,
+				Triggers:          nil,
+			},
+		},
+	}
+	ff, errE := z.New(&config)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	t.Cleanup(func() {
+		// We might double close but we do not care.
+		_ = ff.Close()
+	})
+
+	const goroutines = 10
+	const entries = 100
+
+	var wg sync.WaitGroup
+	for i := range goroutines {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := range entries {
+				config.Logger.Info().Int("goroutine", i).Int("entry", j).Msg("main")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			ctx, closeCtx, _ := config.WithContext(context.Background())
+			defer closeCtx()
+			for j := range entries {
+				zerolog.Ctx(ctx).Info().Int("goroutine", i).Int("entry", j).Msg("context")
+			}
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, ff.Close())
+
+	matches, err := filepath.Glob(p + "*")
+	require.NoError(t, err)
+	assert.Greater(t, len(matches), 1)
+
+	lines := 0
+	for _, match := range matches {
+		content, err := os.ReadFile(filepath.Clean(match))
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(content), 1024)
+		for _, line := range bytes.Split(bytes.TrimSuffix(content, []byte("\n")), []byte("\n")) {
+			// Each line is a whole log entry.
+			assert.True(t, bytes.HasPrefix(line, []byte(`{"level":"info"`)), string(line))
+			assert.True(t, bytes.HasSuffix(line, []byte(`}`)), string(line))
+			lines++
+		}
+	}
+	assert.Equal(t, 2*goroutines*entries, lines)
+}
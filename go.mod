@@ -6,6 +6,7 @@ require (
 	github.com/alecthomas/kong v1.12.2-0.20250922094329-a62e6a47decf
 	github.com/felixge/httpsnoop v1.0.5-0.20250604085516-9a9390b3efa8
 	github.com/goccy/go-yaml v1.18.0
+	github.com/mattn/go-isatty v0.0.20
 	github.com/rs/zerolog v1.34.1-0.20250418111443-9dacc014f38d
 	github.com/stretchr/testify v1.11.1
 	gitlab.com/tozd/go/cli v0.5.1
@@ -32,7 +33,6 @@ require (
 	github.com/kevinburke/ssh_config v1.4.0 // indirect
 	github.com/klauspost/cpuid/v2 v2.3.0 // indirect
 	github.com/mattn/go-colorable v0.1.14 // indirect
-	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/pjbgf/sha1cd v0.5.0 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
@@ -0,0 +1,183 @@
+// Package grpc provides gRPC interceptors which add a context logger
+// to each call, in the same way zerolog.NewHandler does for HTTP requests.
+package grpc
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"gitlab.com/tozd/go/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	z "gitlab.com/tozd/go/zerolog"
+)
+
+// isServerError returns true if the status code signals a server-side error.
+//
+// Those are the status codes which correspond to HTTP status codes 500 or higher.
+func isServerError(code codes.Code) bool {
+	switch code { //nolint:exhaustive
+	case codes.Unknown, codes.DeadlineExceeded, codes.Internal, codes.Unavailable, codes.DataLoss:
+		return true
+	default:
+		return false
+	}
+}
+
+// handle calls the handler with a context logger added to the context.
+func handle(ctx context.Context, withContext z.WithContextFunc, handler func(ctx context.Context) error) error {
+	ctx, closeCtx, trigger := withContext(ctx)
+	if closeCtx != nil {
+		defer closeCtx()
+	}
+	panicking := true
+	var err error
+	if trigger != nil {
+		defer func() {
+			// Errors which are not gRPC status errors have status code Unknown.
+			if panicking || isServerError(status.Code(err)) {
+				trigger()
+			}
+		}()
+	}
+	err = handler(ctx)
+	panicking = false
+	return err
+}
+
+// UnaryServerInterceptor returns a gRPC server interceptor for unary calls
+// which injects log into calls' context.
+//
+// Log entries buffered by the context logger are flushed (triggered) if the call panics
+// or returns an error with a status code signaling a server-side error (Unknown,
+// DeadlineExceeded, Internal, Unavailable, and DataLoss).
+// For other calls the buffered entries are discarded.
+func UnaryServerInterceptor(withContext z.WithContextFunc) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var resp interface{}
+		err := handle(ctx, withContext, func(ctx context.Context) error {
+			var err error
+			resp, err = handler(ctx, req)
+			return err
+		})
+		return resp, err
+	}
+}
+
+// serverStream overrides the context of the wrapped server stream.
+type serverStream struct {
+	grpc.ServerStream
+
+	ctx context.Context //nolint:containedctx
+}
+
+// Context implements grpc.ServerStream interface for serverStream.
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// StreamServerInterceptor returns a gRPC server interceptor for streaming calls
+// which injects log into calls' context.
+//
+// Log entries buffered by the context logger are flushed (triggered) in the same
+// cases as with UnaryServerInterceptor.
+func StreamServerInterceptor(withContext z.WithContextFunc) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handle(ss.Context(), withContext, func(ctx context.Context) error {
+			return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		})
+	}
+}
+
+// logCall logs the finished client call through the context logger.
+//
+// Successful calls are logged at debug level and failed calls at warn level.
+func logCall(ctx context.Context, method, target string, start time.Time, err error) {
+	level := zerolog.DebugLevel
+	if err != nil {
+		level = zerolog.WarnLevel
+	}
+	event := zerolog.Ctx(ctx).WithLevel(level).
+		Str("method", method).
+		Str("target", target).
+		Str("code", status.Code(err).String()).
+		Dur("duration", time.Since(start))
+	if err != nil {
+		event = event.Err(err)
+	}
+	event.Msg("grpc call")
+}
+
+// UnaryClientInterceptor returns a gRPC client interceptor for unary calls
+// which logs every call through the context logger of the call's context.
+//
+// The log entry contains call's method, target, status code, duration,
+// and error (if any).
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption,
+	) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		logCall(ctx, method, cc.Target(), start, err)
+		return err
+	}
+}
+
+// clientStream calls finish once the wrapped client stream is done.
+type clientStream struct {
+	grpc.ClientStream
+
+	serverStreams bool
+	finish        func(err error)
+	once          sync.Once
+}
+
+// RecvMsg implements grpc.ClientStream interface for clientStream.
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	// When the server does not stream, the stream is done after the first (and only)
+	// message. Otherwise it is done once there are no more messages or an error.
+	if err != nil || !s.serverStreams {
+		s.once.Do(func() {
+			if errors.Is(err, io.EOF) {
+				s.finish(nil)
+			} else {
+				s.finish(err)
+			}
+		})
+	}
+	return err
+}
+
+// StreamClientInterceptor returns a gRPC client interceptor for streaming calls
+// which logs every call through the context logger of the call's context.
+//
+// The log entry contains the same fields as with UnaryClientInterceptor and
+// is logged once the stream is done (i.e., once the stream's RecvMsg returns
+// an error or io.EOF). Streams which are not received from until done are not logged.
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		start := time.Now()
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			logCall(ctx, method, cc.Target(), start, err)
+			return nil, err
+		}
+		return &clientStream{
+			ClientStream:  stream,
+			serverStreams: desc.ServerStreams,
+			finish: func(err error) {
+				logCall(ctx, method, cc.Target(), start, err)
+			},
+			once: sync.Once{},
+		}, nil
+	}
+}
@@ -0,0 +1,235 @@
+package grpc_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	z "gitlab.com/tozd/go/zerolog"
+	zgrpc "gitlab.com/tozd/go/zerolog/grpc"
+)
+
+type healthServer struct {
+	healthpb.UnimplementedHealthServer
+}
+
+// result returns the result of the call based on the requested service.
+func result(service string) error {
+	switch service {
+	case "internal":
+		return status.Error(codes.Internal, "internal")
+	case "unavailable":
+		return status.Error(codes.Unavailable, "unavailable")
+	case "notfound":
+		return status.Error(codes.NotFound, "not found")
+	case "unimplemented":
+		return status.Error(codes.Unimplemented, "unimplemented")
+	case "plain":
+		return assert.AnError
+	default:
+		return nil
+	}
+}
+
+func (healthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	zerolog.Ctx(ctx).Debug().Msg("buffered debug")
+	err := result(req.GetService())
+	if err != nil {
+		return nil, err
+	}
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+func (healthServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	zerolog.Ctx(stream.Context()).Debug().Msg("buffered debug")
+	err := stream.Send(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING})
+	if err != nil {
+		return err
+	}
+	return result(req.GetService())
+}
+
+// newLogging configures logging into the buffer.
+func newLogging(t *testing.T, buffer *bytes.Buffer) z.WithContextFunc {
+	t.Helper()
+
+	config := z.LoggingConfig{ //nolint:exhaustruct
+		Logging: z.Logging{ //nolint:exhaustruct
+			Console: z.Console{
+				Type:        "nocolor",
+				Level:       zerolog.DebugLevel,
+				Target:      "stdout",
+				Output:      buffer,
+				ErrorOutput: nil,
+			},
+			Main: z.Main{
+				Level:        zerolog.Disabled,
+				Slog:         false,
+				StdlogLevel:  nil,
+				StdlogCaller: false,
+			},
+			Context: z.Context{
+				Level:             zerolog.DebugLevel,
+				ConditionalLevel:  zerolog.DebugLevel,
+				TriggerLevel:      zerolog.ErrorLevel,
+				MaxEntries:        0,
+				MaxBytes:          0,
+				Summary:           false,
+				SummaryLevel:      zerolog.InfoLevel,
+				TriggerOnDeadline: false,
+				TriggerOnDone:     nil,
+				Triggers:          nil,
+			},
+		},
+	}
+	closer, errE := z.New(&config)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	t.Cleanup(func() {
+		_ = closer.Close()
+	})
+	return config.WithContext
+}
+
+// newClient starts an in-process server with server interceptors
+// and returns a client connected to it with client interceptors.
+func newClient(t *testing.T, serverWithContext z.WithContextFunc) healthpb.HealthClient {
+	t.Helper()
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(zgrpc.UnaryServerInterceptor(serverWithContext)),
+		grpc.StreamInterceptor(zgrpc.StreamServerInterceptor(serverWithContext)),
+	)
+	healthpb.RegisterHealthServer(server, healthServer{})
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(zgrpc.UnaryClientInterceptor()),
+		grpc.WithStreamInterceptor(zgrpc.StreamClientInterceptor()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	return healthpb.NewHealthClient(conn)
+}
+
+func TestServerInterceptors(t *testing.T) {
+	for _, tt := range []struct {
+		Service string
+		Code    codes.Code
+		Flushed bool
+	}{
+		{"", codes.OK, false},
+		{"notfound", codes.NotFound, false},
+		{"unimplemented", codes.Unimplemented, false},
+		{"internal", codes.Internal, true},
+		{"unavailable", codes.Unavailable, true},
+		{"plain", codes.Unknown, true},
+	} {
+		t.Run(tt.Code.String(), func(t *testing.T) {
+			buffer := new(bytes.Buffer)
+			client := newClient(t, newLogging(t, buffer))
+
+			t.Run("unary", func(t *testing.T) {
+				buffer.Reset()
+
+				_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: tt.Service})
+				assert.Equal(t, tt.Code, status.Code(err))
+
+				if tt.Flushed {
+					assert.Regexp(t, `^\d{2}:\d{2} DBG buffered debug\n$`, buffer.String())
+				} else {
+					assert.Empty(t, buffer.String())
+				}
+			})
+
+			t.Run("stream", func(t *testing.T) {
+				buffer.Reset()
+
+				stream, err := client.Watch(context.Background(), &healthpb.HealthCheckRequest{Service: tt.Service})
+				require.NoError(t, err)
+				_, err = stream.Recv()
+				require.NoError(t, err)
+				_, err = stream.Recv()
+				if tt.Code == codes.OK {
+					assert.ErrorIs(t, err, io.EOF)
+				} else {
+					assert.Equal(t, tt.Code, status.Code(err))
+				}
+
+				if tt.Flushed {
+					assert.Regexp(t, `^\d{2}:\d{2} DBG buffered debug\n$`, buffer.String())
+				} else {
+					assert.Empty(t, buffer.String())
+				}
+			})
+		})
+	}
+}
+
+func TestUnaryServerInterceptorPanic(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	interceptor := zgrpc.UnaryServerInterceptor(newLogging(t, buffer))
+
+	assert.PanicsWithValue(t, "boom", func() {
+		_, _ = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, _ interface{}) (interface{}, error) { //nolint:exhaustruct
+			zerolog.Ctx(ctx).Debug().Msg("buffered debug")
+			panic("boom")
+		})
+	})
+
+	assert.Regexp(t, `^\d{2}:\d{2} DBG buffered debug\n$`, buffer.String())
+}
+
+func TestClientInterceptors(t *testing.T) {
+	client := newClient(t, newLogging(t, new(bytes.Buffer)))
+
+	buffer := new(bytes.Buffer)
+	withContext := newLogging(t, buffer)
+
+	ctx, closeCtx, trigger := withContext(context.Background())
+	defer closeCtx()
+
+	_, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: ""})
+	require.NoError(t, err)
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "notfound"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{Service: ""})
+	require.NoError(t, err)
+	for err == nil {
+		_, err = stream.Recv()
+	}
+	assert.ErrorIs(t, err, io.EOF)
+
+	// Successful calls are buffered at debug level.
+	trigger()
+
+	output := buffer.String()
+	assert.Regexp(t, `(?m)^\d{2}:\d{2} WRN grpc call .*code=NotFound duration=\d+\.\d+ method=/grpc.health.v1.Health/Check target=passthrough:///bufnet`, output)
+	assert.Regexp(t, `(?m)^\d{2}:\d{2} DBG grpc call code=OK duration=\d+\.\d+ method=/grpc.health.v1.Health/Check target=passthrough:///bufnet$`, output)
+	assert.Regexp(t, `(?m)^\d{2}:\d{2} DBG grpc call code=OK duration=\d+\.\d+ method=/grpc.health.v1.Health/Watch target=passthrough:///bufnet$`, output)
+	assert.Contains(t, output, "not found")
+}
@@ -0,0 +1,391 @@
+package zerolog
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/felixge/httpsnoop"
+	"github.com/rs/zerolog"
+	"gitlab.com/tozd/go/errors"
+)
+
+const (
+	// DefaultRequestIDHeader is the default request header from which the
+	// request ID is read and the response header to which it is written.
+	DefaultRequestIDHeader = "X-Request-ID"
+
+	// DefaultDebugHeader is the default request header from which
+	// the level for debug escalation is read.
+	DefaultDebugHeader = "X-Debug-Log"
+
+	// Incoming request IDs longer than this are ignored.
+	maxRequestIDLength = 128
+)
+
+type handlerOptions struct {
+	accessLog       bool
+	requestIDHeader string
+	traceContext    bool
+	recover         bool
+	triggerPolicy   TriggerPolicy
+	debug           *DebugEscalation
+}
+
+type requestIDKey struct{}
+
+// HandlerOption configures NewHandler.
+type HandlerOption func(*handlerOptions)
+
+// WithAccessLog makes NewHandler log one access log entry per request
+// through the request's context logger, after the request is done.
+//
+// The log entry contains request's method, path, route pattern (if routed
+// with http.ServeMux), response status code, response size, duration,
+// remote address, and user agent. Log entries for responses with server
+// errors (status code 500 or higher) are logged at error level, client errors
+// (status code 400 or higher) at warn level, and other at info level.
+//
+// The access log entry is written out directly and does not itself flush
+// (trigger) log entries buffered by the context logger, so that only
+// the trigger policy (see WithTriggerPolicy) decides that.
+func WithAccessLog() HandlerOption {
+	return func(o *handlerOptions) {
+		o.accessLog = true
+	}
+}
+
+// WithRequestID makes NewHandler assign an ID to every request.
+//
+// The request ID is read from the request header (DefaultRequestIDHeader if
+// header is empty) or generated if the request does not have a valid one.
+// It is added as requestId field to the request's context logger, set
+// in the response header, and available from the request's context using RequestID.
+func WithRequestID(header string) HandlerOption {
+	if header == "" {
+		header = DefaultRequestIDHeader
+	}
+	return func(o *handlerOptions) {
+		o.requestIDHeader = header
+	}
+}
+
+// RequestID returns the request ID assigned by NewHandler to the request
+// with the context, if any.
+func RequestID(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok
+}
+
+// validRequestID returns true if the request ID is reasonably short
+// and contains only printable ASCII characters.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := range len(id) {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// WithTraceContext makes NewHandler add a W3C Trace Context to every request.
+//
+// The trace context is parsed from the request's traceparent and tracestate
+// headers or a new trace is generated if the request does not have a valid one.
+// See TracedWithContext for details.
+func WithTraceContext() HandlerOption {
+	return func(o *handlerOptions) {
+		o.traceContext = true
+	}
+}
+
+// WithRecover makes NewHandler recover from a panic in the request's handler.
+//
+// The panic is logged through the request's context logger at error level,
+// as an error with the panic value and the stack trace of the panic. If the
+// response headers have not yet been written, it responds with status code 500.
+//
+// Panics with http.ErrAbortHandler are not recovered.
+func WithRecover() HandlerOption {
+	return func(o *handlerOptions) {
+		o.recover = true
+	}
+}
+
+// TriggerPolicy configures when NewHandler flushes (triggers) log entries buffered
+// by the request's context logger after the request is done. Requests which panic
+// always trigger.
+//
+// The request triggers if its response status code is in Statuses or Status returns
+// true for it. If both Statuses and Status are not set, server errors (status
+// code 500 or higher) trigger. The request also triggers if it took longer than Latency
+// (when Latency is not zero) or if Size returns true for the response size in bytes.
+type TriggerPolicy struct {
+	Statuses []int
+	Status   func(code int) bool
+	Latency  time.Duration
+	Size     func(size int64) bool
+}
+
+// triggers returns true if the request with the response status code,
+// duration, and response size should trigger.
+func (p TriggerPolicy) triggers(code int, duration time.Duration, size int64) bool {
+	if p.Statuses == nil && p.Status == nil {
+		if code >= http.StatusInternalServerError {
+			return true
+		}
+	} else if slices.Contains(p.Statuses, code) || (p.Status != nil && p.Status(code)) {
+		return true
+	}
+	if p.Latency > 0 && duration > p.Latency {
+		return true
+	}
+	return p.Size != nil && p.Size(size)
+}
+
+// WithTriggerPolicy configures NewHandler to flush (trigger) buffered log entries
+// of the request's context logger based on the policy instead of only on
+// server errors.
+func WithTriggerPolicy(policy TriggerPolicy) HandlerOption {
+	return func(o *handlerOptions) {
+		o.triggerPolicy = policy
+	}
+}
+
+// DebugEscalation configures per-request debug escalation in NewHandler.
+//
+// An authorized request can provide a level (e.g., trace) in the Header
+// (DefaultDebugHeader if empty) or in the Query parameter (if not empty).
+// If the level is lower than the level of the request's context logger,
+// the context logger's level is lowered to it for that request only.
+// Moreover, the context logger is triggered at the start of the request,
+// so that all its log entries are written out.
+//
+// Requests are authorized by Authorize (e.g., see SharedSecret).
+// If Authorize is nil, no request is authorized.
+//
+// Log entries are still filtered by levels of logging outputs
+// (e.g., Console's Level).
+type DebugEscalation struct {
+	Header    string
+	Query     string
+	Authorize func(req *http.Request) bool
+}
+
+// level returns the level requested by the request and if the
+// request is authorized to escalate to it.
+func (d *DebugEscalation) level(req *http.Request) (zerolog.Level, bool) {
+	header := d.Header
+	if header == "" {
+		header = DefaultDebugHeader
+	}
+	value := req.Header.Get(header)
+	if value == "" && d.Query != "" {
+		value = req.URL.Query().Get(d.Query)
+	}
+	if value == "" {
+		return zerolog.NoLevel, false
+	}
+	level, err := zerolog.ParseLevel(value)
+	if err != nil || level == zerolog.NoLevel || level == zerolog.Disabled {
+		return zerolog.NoLevel, false
+	}
+	if d.Authorize == nil || !d.Authorize(req) {
+		return zerolog.NoLevel, false
+	}
+	return level, true
+}
+
+// WithDebugEscalation makes NewHandler support per-request debug escalation.
+func WithDebugEscalation(config DebugEscalation) HandlerOption {
+	return func(o *handlerOptions) {
+		o.debug = &config
+	}
+}
+
+// SharedSecret returns a function which authorizes requests with the header
+// equal to the secret. It can be used for DebugEscalation's Authorize.
+//
+// If the secret is empty, no request is authorized.
+func SharedSecret(header, secret string) func(req *http.Request) bool {
+	return func(req *http.Request) bool {
+		if secret == "" {
+			return false
+		}
+		return subtle.ConstantTimeCompare([]byte(req.Header.Get(header)), []byte(secret)) == 1
+	}
+}
+
+// panicError converts the value recovered from a panic into an error
+// with the stack trace of the panic.
+func panicError(v interface{}) errors.E {
+	if err, ok := v.(error); ok {
+		return errors.Wrap(err, "panic")
+	}
+	errE := errors.New("panic")
+	errors.Details(errE)["value"] = fmt.Sprint(v)
+	return errE
+}
+
+// accessLogLevel returns the level at which the access log entry for
+// the response status code is logged.
+func accessLogLevel(code int) zerolog.Level {
+	switch {
+	case code >= http.StatusInternalServerError:
+		return zerolog.ErrorLevel
+	case code >= http.StatusBadRequest:
+		return zerolog.WarnLevel
+	default:
+		return zerolog.InfoLevel
+	}
+}
+
+// NewHandler injects log into requests context.
+//
+// Log entries buffered by the context logger are flushed (triggered) if the request panics or
+// responds with a server error (status code 500 or higher). For other responses the buffered
+// entries are discarded. This makes the detailed debug logging of a request available exactly
+// when something went wrong with the request, even when it does not panic and does not itself
+// log through the context logger at the triggering level. Use WithTriggerPolicy to configure
+// when to trigger instead.
+//
+// If the context logger is configured to trigger when its context ends (see Context's
+// TriggerOnDeadline and TriggerOnDone), buffered entries are flushed also when the request's
+// context ends before the request is done, e.g., when its deadline is exceeded or when
+// the client aborts the request.
+//
+// Options can enable additional behavior, e.g., WithAccessLog, WithRequestID, WithTraceContext,
+// WithRecover, WithTriggerPolicy, and WithDebugEscalation.
+func NewHandler(withContext WithContextFunc, options ...HandlerOption) func(http.Handler) http.Handler {
+	var o handlerOptions
+	for _, option := range options {
+		option(&o)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			start := time.Now()
+			ctx, closeCtx, trigger := withContext(req.Context())
+			if closeCtx != nil {
+				defer closeCtx()
+			}
+			if o.requestIDHeader != "" {
+				// If the request already has an ID (e.g., when NewHandler is nested),
+				// its context logger already has the field as well.
+				id, ok := RequestID(ctx)
+				if !ok {
+					id = req.Header.Get(o.requestIDHeader)
+					if !validRequestID(id) {
+						id = rand.Text()
+					}
+					ctx = context.WithValue(ctx, requestIDKey{}, id)
+					logger := zerolog.Ctx(ctx).With().Str("requestId", id).Logger()
+					ctx = withContextLogger(ctx, logger)
+				}
+				w.Header().Set(o.requestIDHeader, id)
+			}
+			if o.traceContext {
+				// Multiple tracestate headers are combined into one list.
+				ctx = withTrace(ctx, req.Header.Get(TraceParentHeader), strings.Join(req.Header.Values(TraceStateHeader), ","))
+			}
+			if o.debug != nil {
+				if level, ok := o.debug.level(req); ok {
+					logger := zerolog.Ctx(ctx)
+					if level < logger.GetLevel() {
+						ctx = withContextLogger(ctx, logger.Level(level))
+					}
+					if trigger != nil {
+						trigger()
+					}
+				}
+			}
+			panicking := true
+			recovered := false
+			wroteHeader := false
+			code := http.StatusOK
+			size := int64(0)
+			if trigger != nil || o.accessLog || o.recover {
+				defer func() {
+					if panicking && o.recover {
+						// recover returns nil if the handler called runtime.Goexit.
+						v := recover()
+						if v == http.ErrAbortHandler {
+							panic(v)
+						} else if v != nil {
+							recovered = true
+							zerolog.Ctx(ctx).Error().Err(panicError(v)).Msg("recovered from panic")
+							if !wroteHeader {
+								w.WriteHeader(http.StatusInternalServerError)
+							}
+						}
+					}
+					if trigger != nil && (panicking || o.triggerPolicy.triggers(code, time.Since(start), size)) {
+						trigger()
+					}
+					if o.accessLog && (!panicking || recovered) {
+						logger := passThroughLogger(ctx)
+						logger.WithLevel(accessLogLevel(code)).
+							Str("method", req.Method).
+							Str("path", req.URL.Path).
+							Str("route", req.Pattern).
+							Int("status", code).
+							Int64("size", size).
+							Dur("duration", time.Since(start)).
+							Str("remote", req.RemoteAddr).
+							Str("userAgent", req.UserAgent()).
+							Msg("request")
+					}
+				}()
+				// We capture the response status code so that we can flush buffered log entries on a server
+				// error even when the request returns normally. If WriteHeader is never called, the response
+				// status code is 200, which is the default we initialize code with.
+				w = httpsnoop.Wrap(w, httpsnoop.Hooks{ //nolint:exhaustruct
+					WriteHeader: func(next httpsnoop.WriteHeaderFunc) httpsnoop.WriteHeaderFunc {
+						return func(c int) {
+							code = c
+							// Informational responses do not write the final response headers.
+							if c >= http.StatusOK {
+								wroteHeader = true
+							}
+							next(c)
+						}
+					},
+					Write: func(next httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+						return func(b []byte) (int, error) {
+							wroteHeader = true
+							n, err := next(b)
+							size += int64(n)
+							return n, err
+						}
+					},
+					ReadFrom: func(next httpsnoop.ReadFromFunc) httpsnoop.ReadFromFunc {
+						return func(src io.Reader) (int64, error) {
+							wroteHeader = true
+							n, err := next(src)
+							size += n
+							return n, err
+						}
+					},
+					Flush: func(next httpsnoop.FlushFunc) httpsnoop.FlushFunc {
+						return func() {
+							wroteHeader = true
+							next()
+						}
+					},
+				})
+			}
+			req = req.WithContext(ctx)
+			next.ServeHTTP(w, req)
+			panicking = false
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package zerolog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog"
+	"gitlab.com/tozd/go/errors"
+)
+
+const (
+	defaultJournaldSocket = "/run/systemd/journal/socket"
+	// Journald limits field names to 64 characters.
+	journaldMaxFieldName = 64
+	// Prefix for journal field names which would collide with fields set by JournaldWriter.
+	journaldFieldPrefix = "FIELD_"
+)
+
+// journaldFieldName converts a log entry's field name into a journal field name
+// which does not collide with fields set by JournaldWriter itself.
+//
+// It returns an empty string if nothing is left.
+func journaldFieldName(name string) string {
+	n := sanitizeJournaldFieldName(name)
+	switch {
+	case n == "PRIORITY", n == "SYSLOG_IDENTIFIER", n == "MESSAGE", n == "ERROR", strings.HasPrefix(n, "ERROR_"):
+		n = journaldFieldPrefix + n
+		if len(n) > journaldMaxFieldName {
+			n = n[:journaldMaxFieldName]
+		}
+	}
+	return n
+}
+
+// sanitizeJournaldFieldName converts a log entry's field name into a journal field name.
+//
+// Journal field names can contain only uppercase letters, digits, and underscores.
+// They cannot start with a digit or an underscore (those are reserved for trusted fields).
+// It returns an empty string if nothing is left.
+func sanitizeJournaldFieldName(name string) string {
+	b := []byte(strings.ToUpper(name))
+	for i, c := range b {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			b[i] = '_'
+		}
+	}
+	b = bytes.TrimLeft(b, "_0123456789")
+	if len(b) > journaldMaxFieldName {
+		b = b[:journaldMaxFieldName]
+	}
+	return string(b)
+}
+
+// appendJournaldField appends the field to the buffer using the journal's native protocol.
+//
+// Values containing a newline are serialized as binary data prefixed with their length.
+func appendJournaldField(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name)
+	if strings.Contains(value, "\n") {
+		buf.WriteString("\n")
+		_ = binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	} else {
+		buf.WriteString("=")
+	}
+	buf.WriteString(value)
+	buf.WriteString("\n")
+}
+
+// formatJournaldStack formats the error's stack trace (as marshaled into JSON
+// by gitlab.com/tozd/go/errors) in the same way Go formats stack traces.
+func formatJournaldStack(value json.RawMessage) string {
+	var frames []stackFrame
+	err := json.Unmarshal(value, &frames)
+	if err != nil {
+		return string(value)
+	}
+	var b strings.Builder
+	for _, frame := range frames {
+		b.WriteString(frame.Name)
+		b.WriteString("\n\t")
+		b.WriteString(frame.File)
+		b.WriteString(":")
+		b.WriteString(strconv.Itoa(frame.Line))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// JournaldWriter sends log entries to journald using its native protocol
+// over a unix datagram socket.
+//
+// Top-level fields of the log entry are converted into journal fields with
+// uppercase names and the level is mapped to journal's PRIORITY. The error
+// (marshaled into JSON object using gitlab.com/tozd/go/errors's Formatter)
+// is split into ERROR field with the error message, ERROR_STACK field with the
+// stack trace, and other ERROR_ prefixed fields for the rest of the error.
+// Other fields which would collide with PRIORITY, SYSLOG_IDENTIFIER, MESSAGE,
+// or ERROR fields are prefixed with FIELD_.
+//
+// Log entries which are too large to be sent as a datagram are sent
+// through a temporary file (only on Linux).
+//
+// JournaldWriter connects to the socket on the first write.
+// If sending fails, JournaldWriter reconnects and tries again once.
+//
+// It is safe for concurrent use.
+type JournaldWriter struct {
+	socket     string
+	identifier string
+
+	mu     sync.Mutex
+	conn   *net.UnixConn
+	closed bool
+}
+
+// NewJournaldWriter returns a JournaldWriter sending log entries to the configured socket.
+//
+// It does not connect to the socket, so journald does not have to be
+// available yet. Connection errors are returned from writes.
+func NewJournaldWriter(config Journald) (*JournaldWriter, errors.E) {
+	socket := config.Socket
+	if socket == "" {
+		socket = defaultJournaldSocket
+	}
+
+	w := &JournaldWriter{
+		socket:     socket,
+		identifier: filepath.Base(os.Args[0]),
+		mu:         sync.Mutex{},
+		conn:       nil,
+		closed:     false,
+	}
+
+	return w, nil
+}
+
+// connect connects to the journald socket.
+//
+// It expects the caller to hold the lock (or to have exclusive access).
+func (w *JournaldWriter) connect() errors.E {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: w.socket, Net: "unixgram"})
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot connect to journald")
+		errors.Details(errE)["socket"] = w.socket
+		return errE
+	}
+	w.conn = conn
+	return nil
+}
+
+// format formats the log entry at the level as a journal entry.
+func (w *JournaldWriter) format(level zerolog.Level, p []byte) []byte {
+	entry := bytes.TrimSuffix(p, []byte("\n"))
+
+	var buf bytes.Buffer
+	appendJournaldField(&buf, "PRIORITY", strconv.Itoa(syslogSeverity(level)))
+	appendJournaldField(&buf, "SYSLOG_IDENTIFIER", w.identifier)
+
+	fields, errE := parseEntry(entry)
+	if errE != nil {
+		// If the log entry is not JSON, we send it as-is.
+		appendJournaldField(&buf, "MESSAGE", string(entry))
+		return buf.Bytes()
+	}
+
+	hasMessage := false
+	for _, field := range fields {
+		switch field.Key {
+		case zerolog.LevelFieldName:
+		case zerolog.MessageFieldName:
+			hasMessage = true
+			appendJournaldField(&buf, "MESSAGE", fieldString(field.Value))
+		case zerolog.ErrorFieldName:
+			w.appendError(&buf, field.Value)
+		default:
+			name := journaldFieldName(field.Key)
+			if name == "" {
+				continue
+			}
+			appendJournaldField(&buf, name, fieldString(field.Value))
+		}
+	}
+	if !hasMessage {
+		// Journal entries without a message are hard to inspect,
+		// so we use the whole log entry as the message.
+		appendJournaldField(&buf, "MESSAGE", string(entry))
+	}
+
+	return buf.Bytes()
+}
+
+// appendError appends the error field. If the error is a JSON object, its
+// message, stack trace, and other fields are appended as separate fields.
+func (w *JournaldWriter) appendError(buf *bytes.Buffer, value json.RawMessage) {
+	fields, errE := parseEntry(value)
+	if errE != nil {
+		appendJournaldField(buf, "ERROR", fieldString(value))
+		return
+	}
+	for _, field := range fields {
+		switch field.Key {
+		case "error":
+			appendJournaldField(buf, "ERROR", fieldString(field.Value))
+		case "stack":
+			appendJournaldField(buf, "ERROR_STACK", formatJournaldStack(field.Value))
+		default:
+			name := sanitizeJournaldFieldName(field.Key)
+			if name == "" {
+				continue
+			}
+			appendJournaldField(buf, "ERROR_"+name, fieldString(field.Value))
+		}
+	}
+}
+
+// send sends the journal entry, through a temporary file if it is too large.
+//
+// It expects the caller to hold the lock.
+func (w *JournaldWriter) send(msg []byte) errors.E {
+	_, err := w.conn.Write(msg)
+	if err == nil {
+		return nil
+	}
+	if isMessageTooLarge(err) {
+		return sendJournaldFile(w.conn, msg)
+	}
+	return errors.WithMessage(err, "cannot send to journald")
+}
+
+// Write implements io.Writer interface for JournaldWriter.
+//
+// The log entry is sent without a level.
+func (w *JournaldWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(zerolog.NoLevel, p)
+}
+
+// WriteLevel implements zerolog.LevelWriter interface for JournaldWriter.
+//
+// Each call is expected to contain one whole log entry.
+func (w *JournaldWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	msg := w.format(level, p)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, errors.WithStack(os.ErrClosed)
+	}
+
+	if w.conn != nil {
+		errE := w.send(msg)
+		if errE == nil {
+			return len(p), nil
+		}
+		_ = w.conn.Close()
+		w.conn = nil
+	}
+
+	// We (re)connect and try again.
+	errE := w.connect()
+	if errE != nil {
+		return 0, errE
+	}
+	errE = w.send(msg)
+	if errE != nil {
+		_ = w.conn.Close()
+		w.conn = nil
+		return 0, errE
+	}
+	return len(p), nil
+}
+
+// Close implements io.Closer interface for JournaldWriter.
+func (w *JournaldWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.closed = true
+	if w.conn == nil {
+		return nil
+	}
+
+	err := w.conn.Close()
+	w.conn = nil
+	return errors.WithStack(err)
+}
+
+var _ zerolog.LevelWriter = (*JournaldWriter)(nil)
+
+var _ io.Closer = (*JournaldWriter)(nil)
@@ -0,0 +1,58 @@
+//go:build linux
+
+package zerolog
+
+import (
+	"net"
+	"os"
+	"syscall"
+
+	"gitlab.com/tozd/go/errors"
+)
+
+// isMessageTooLarge returns true if the error is because the datagram is too large.
+func isMessageTooLarge(err error) bool {
+	return errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS)
+}
+
+// sendJournaldFile writes the journal entry into a temporary file and
+// sends its file descriptor to journald.
+func sendJournaldFile(conn *net.UnixConn, msg []byte) errors.E {
+	file, err := os.CreateTemp("/dev/shm", "journald-")
+	if err != nil {
+		return errors.WithMessage(err, "cannot create temporary file for journald")
+	}
+	defer file.Close()
+
+	// Only the file descriptor is sent so the file itself is not needed anymore.
+	err = os.Remove(file.Name())
+	if err != nil {
+		return errors.WithMessage(err, "cannot remove temporary file for journald")
+	}
+
+	_, err = file.Write(msg)
+	if err != nil {
+		return errors.WithMessage(err, "cannot write temporary file for journald")
+	}
+
+	// We cannot use WriteMsgUnix because it does not support connected datagram sockets.
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return errors.WithMessage(err, "cannot send to journald")
+	}
+	rights := syscall.UnixRights(int(file.Fd()))
+	var sendErr error
+	err = rawConn.Write(func(fd uintptr) bool {
+		sendErr = syscall.Sendmsg(int(fd), nil, rights, nil, 0)
+		// We wait for the socket to become writable and try again.
+		return !errors.Is(sendErr, syscall.EAGAIN)
+	})
+	if err == nil {
+		err = sendErr
+	}
+	if err != nil {
+		return errors.WithMessage(err, "cannot send to journald")
+	}
+
+	return nil
+}
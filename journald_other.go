@@ -0,0 +1,21 @@
+//go:build !linux
+
+package zerolog
+
+import (
+	"net"
+
+	"gitlab.com/tozd/go/errors"
+)
+
+// isMessageTooLarge returns true if the error is because the datagram is too large.
+//
+// Sending large journal entries is supported only on Linux.
+func isMessageTooLarge(_ error) bool {
+	return false
+}
+
+// sendJournaldFile is not supported on this platform.
+func sendJournaldFile(_ *net.UnixConn, _ []byte) errors.E {
+	return errors.New("sending large journal entries is not supported")
+}
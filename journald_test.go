@@ -0,0 +1,244 @@
+//go:build unix
+
+package zerolog_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	z "gitlab.com/tozd/go/zerolog"
+)
+
+// journaldListener creates a unix datagram listener standing in for journald.
+func journaldListener(t *testing.T) (*net.UnixConn, string) {
+	t.Helper()
+
+	p := filepath.Join(t.TempDir(), "socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: p, Net: "unixgram"})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return conn, p
+}
+
+// readJournaldEntry reads one journal entry sent using journal's native protocol
+// and returns its fields as name=value strings.
+func readJournaldEntry(t *testing.T, conn *net.UnixConn) []string {
+	t.Helper()
+
+	buf := make([]byte, 64*1024)
+	oob := make([]byte, 1024)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	require.NoError(t, err)
+	data := buf[:n]
+
+	if oobn > 0 {
+		// The entry has been sent through a file.
+		messages, err := syscall.ParseSocketControlMessage(oob[:oobn])
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		fds, err := syscall.ParseUnixRights(&messages[0])
+		require.NoError(t, err)
+		require.Len(t, fds, 1)
+		file := os.NewFile(uintptr(fds[0]), "journald")
+		defer file.Close()
+		data, err = io.ReadAll(io.NewSectionReader(file, 0, 1<<32))
+		require.NoError(t, err)
+	}
+
+	fields := []string{}
+	for len(data) > 0 {
+		i := bytes.IndexAny(data, "=\n")
+		require.GreaterOrEqual(t, i, 0)
+		name := string(data[:i])
+		if data[i] == '=' {
+			j := bytes.IndexByte(data, '\n')
+			require.GreaterOrEqual(t, j, 0)
+			fields = append(fields, name+"="+string(data[i+1:j]))
+			data = data[j+1:]
+		} else {
+			size := binary.LittleEndian.Uint64(data[i+1 : i+9])
+			fields = append(fields, name+"="+string(data[i+9:i+9+int(size)]))
+			require.Equal(t, byte('\n'), data[i+9+int(size)])
+			data = data[i+9+int(size)+1:]
+		}
+	}
+	return fields
+}
+
+func TestJournaldWriter(t *testing.T) {
+	conn, p := journaldListener(t)
+
+	w, errE := z.NewJournaldWriter(z.Journald{ //nolint:exhaustruct
+		Socket: p,
+	})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	t.Cleanup(func() {
+		// We might double close but we do not care.
+		_ = w.Close()
+	})
+
+	identifier := "SYSLOG_IDENTIFIER=" + filepath.Base(os.Args[0])
+
+	_, err := w.WriteLevel(zerolog.ErrorLevel, []byte(`{"level":"error","error":{"error":"boom","foo":"bar","stack":[{"name":"main.f","file":"/x.go","line":10},{"name":"main.main","file":"/main.go","line":3}]},"foo-bar":"x","n":1,"1st":"y","_":"z","message":"multi\nline"}`+"\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"PRIORITY=3",
+		identifier,
+		"ERROR=boom",
+		"ERROR_FOO=bar",
+		"ERROR_STACK=main.f\n\t/x.go:10\nmain.main\n\t/main.go:3\n",
+		"FOO_BAR=x",
+		"N=1",
+		"ST=y",
+		"MESSAGE=multi\nline",
+	}, readJournaldEntry(t, conn))
+
+	_, err = w.WriteLevel(zerolog.WarnLevel, []byte(`{"level":"warn","error":"plain"}`+"\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"PRIORITY=4",
+		identifier,
+		"ERROR=plain",
+		`MESSAGE={"level":"warn","error":"plain"}`,
+	}, readJournaldEntry(t, conn))
+
+	// Fields colliding with fields set by the writer are prefixed.
+	_, err = w.WriteLevel(zerolog.InfoLevel, []byte(`{"level":"info","priority":"p","syslog_identifier":"s","Message":"m","ERROR":"e","error_foo":"f","message":"hello"}`+"\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"PRIORITY=6",
+		identifier,
+		"FIELD_PRIORITY=p",
+		"FIELD_SYSLOG_IDENTIFIER=s",
+		"FIELD_MESSAGE=m",
+		"FIELD_ERROR=e",
+		"FIELD_ERROR_FOO=f",
+		"MESSAGE=hello",
+	}, readJournaldEntry(t, conn))
+
+	_, err = w.Write([]byte("not json\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"PRIORITY=6",
+		identifier,
+		"MESSAGE=not json",
+	}, readJournaldEntry(t, conn))
+
+	require.NoError(t, w.Close())
+
+	_, err = w.Write([]byte(`{"message":"closed"}`))
+	assert.ErrorIs(t, err, os.ErrClosed)
+}
+
+func TestJournaldWriterLargeEntry(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("large journal entries are supported only on Linux")
+	}
+
+	conn, p := journaldListener(t)
+
+	w, errE := z.NewJournaldWriter(z.Journald{ //nolint:exhaustruct
+		Socket: p,
+	})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	t.Cleanup(func() {
+		_ = w.Close()
+	})
+
+	message := strings.Repeat("x", 1024*1024)
+	_, err := w.WriteLevel(zerolog.InfoLevel, []byte(`{"level":"info","message":"`+message+`"}`+"\n"))
+	require.NoError(t, err)
+
+	fields := readJournaldEntry(t, conn)
+	require.Len(t, fields, 3)
+	assert.Equal(t, "MESSAGE="+message, fields[2])
+}
+
+func TestJournaldWriterMissingSocket(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "socket")
+
+	// Journald is not available yet.
+	w, errE := z.NewJournaldWriter(z.Journald{ //nolint:exhaustruct
+		Socket: p,
+	})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	t.Cleanup(func() {
+		_ = w.Close()
+	})
+
+	_, err := w.Write([]byte(`{"message":"lost"}`))
+	assert.ErrorContains(t, err, "cannot connect to journald")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: p, Net: "unixgram"})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	_, err = w.Write([]byte(`{"message":"hello"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "MESSAGE=hello", readJournaldEntry(t, conn)[2])
+}
+
+func TestJournald(t *testing.T) {
+	conn, p := journaldListener(t)
+
+	config := z.LoggingConfig{
+		Logger:      zerolog.Nop(),
+		WithContext: nil,
+		Logging: z.Logging{ //nolint:exhaustruct
+			Console: z.Console{
+				Type:        "disable",
+				Level:       zerolog.DebugLevel,
+				Target:      "stdout",
+				Output:      nil,
+				ErrorOutput: nil,
+			},
+			Journald: z.Journald{
+				Enable: true,
+				Level:  zerolog.InfoLevel,
+				Socket: p,
+			},
+			Main: z.Main{
+				Level:        zerolog.DebugLevel,
+				Slog:         false,
+				StdlogLevel:  nil,
+				StdlogCaller: false,
+			},
+		},
+	}
+	closer, errE := z.New(&config)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	t.Cleanup(func() {
+		// We might double close but we do not care.
+		_ = closer.Close()
+	})
+
+	config.Logger.Debug().Msg("filtered out")
+	config.Logger.Warn().Str("foo", "bar").Msg("test")
+
+	require.NoError(t, closer.Close())
+
+	fields := readJournaldEntry(t, conn)
+	require.Len(t, fields, 5)
+	assert.Equal(t, "PRIORITY=4", fields[0])
+	assert.Equal(t, "FOO=bar", fields[2])
+	assert.Regexp(t, `^TIME=\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{3}Z$`, fields[3])
+	assert.Equal(t, "MESSAGE=test", fields[4])
+}
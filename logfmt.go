@@ -0,0 +1,168 @@
+package zerolog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"gitlab.com/tozd/go/errors"
+)
+
+// logfmtWriter formats JSON log entries as logfmt (key=value pairs) and writes them to Out.
+//
+// Timestamp, level, and message fields are written first, followed by other fields
+// in their original order. Nested objects are flattened using dots in keys.
+// The error (marshaled into JSON object using gitlab.com/tozd/go/errors's Formatter)
+// is flattened into its message, details, the first frame of its stack trace,
+// and recursively its cause and joined errors.
+//
+// Log entries which are not JSON objects are written as-is.
+type logfmtWriter struct {
+	Out io.Writer
+}
+
+// Write implements io.Writer interface for logfmtWriter.
+//
+// Each call is expected to contain one whole log entry.
+func (w *logfmtWriter) Write(p []byte) (int, error) {
+	fields, errE := parseEntry(p)
+	if errE != nil {
+		_, err := w.Out.Write(p)
+		if err != nil {
+			return 0, errors.WithStack(err)
+		}
+		return len(p), nil
+	}
+
+	var buf bytes.Buffer
+	for _, key := range []string{zerolog.TimestampFieldName, zerolog.LevelFieldName, zerolog.MessageFieldName} {
+		for _, field := range fields {
+			if field.Key == key {
+				appendLogfmtValue(&buf, field.Key, field.Value)
+			}
+		}
+	}
+	for _, field := range fields {
+		switch field.Key {
+		case zerolog.TimestampFieldName, zerolog.LevelFieldName, zerolog.MessageFieldName:
+		case zerolog.ErrorFieldName:
+			appendLogfmtError(&buf, field.Key, field.Value)
+		default:
+			appendLogfmtValue(&buf, field.Key, field.Value)
+		}
+	}
+	buf.WriteString("\n")
+
+	_, err := w.Out.Write(buf.Bytes())
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return len(p), nil
+}
+
+// appendLogfmtPair appends key=value pair to the buffer, quoting the value if necessary.
+func appendLogfmtPair(buf *bytes.Buffer, key, value string, quote bool) {
+	if buf.Len() > 0 {
+		buf.WriteString(" ")
+	}
+	buf.WriteString(strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' {
+			return '_'
+		}
+		return r
+	}, key))
+	buf.WriteString("=")
+	if quote && logfmtNeedsQuote(value) {
+		buf.WriteString(strconv.Quote(value))
+	} else {
+		buf.WriteString(value)
+	}
+}
+
+// logfmtNeedsQuote returns true if the value has to be quoted in logfmt.
+func logfmtNeedsQuote(value string) bool {
+	if value == "" {
+		return true
+	}
+	for _, r := range value {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || !strconv.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendLogfmtValue appends the JSON value under the key to the buffer.
+//
+// Objects are flattened recursively while arrays are appended as JSON.
+func appendLogfmtValue(buf *bytes.Buffer, key string, value json.RawMessage) {
+	value = bytes.TrimSpace(value)
+	switch {
+	case len(value) > 0 && value[0] == '{':
+		fields, errE := parseEntry(value)
+		if errE != nil || len(fields) == 0 {
+			appendLogfmtPair(buf, key, string(value), true)
+			return
+		}
+		for _, field := range fields {
+			appendLogfmtValue(buf, key+"."+field.Key, field.Value)
+		}
+	case len(value) > 0 && value[0] == '[':
+		var compact bytes.Buffer
+		err := json.Compact(&compact, value)
+		if err != nil {
+			appendLogfmtPair(buf, key, string(value), true)
+			return
+		}
+		appendLogfmtPair(buf, key, compact.String(), true)
+	case bytes.Equal(value, []byte("null")):
+		appendLogfmtPair(buf, key, "", false)
+	default:
+		appendLogfmtPair(buf, key, fieldString(value), true)
+	}
+}
+
+// appendLogfmtError appends the error under the key to the buffer.
+//
+// If the error is a JSON object, it appends error's message under the key,
+// the first frame of error's stack trace under key.stack, cause and joined
+// errors recursively under key.cause and key.errors.N, and other fields
+// (details) under key.field.
+func appendLogfmtError(buf *bytes.Buffer, key string, value json.RawMessage) {
+	fields, errE := parseEntry(value)
+	if errE != nil {
+		appendLogfmtValue(buf, key, value)
+		return
+	}
+	for _, field := range fields {
+		switch field.Key {
+		case "error":
+			appendLogfmtPair(buf, key, fieldString(field.Value), true)
+		case "stack":
+			var frames []stackFrame
+			err := json.Unmarshal(field.Value, &frames)
+			if err != nil || len(frames) == 0 {
+				appendLogfmtValue(buf, key+".stack", field.Value)
+				continue
+			}
+			appendLogfmtPair(buf, key+".stack", frames[0].Name+" "+frames[0].File+":"+strconv.Itoa(frames[0].Line), true)
+		case "cause":
+			appendLogfmtError(buf, key+".cause", field.Value)
+		case "errors":
+			var errs []json.RawMessage
+			err := json.Unmarshal(field.Value, &errs)
+			if err != nil {
+				appendLogfmtValue(buf, key+".errors", field.Value)
+				continue
+			}
+			for i, e := range errs {
+				appendLogfmtError(buf, key+".errors."+strconv.Itoa(i), e)
+			}
+		default:
+			appendLogfmtValue(buf, key+"."+field.Key, field.Value)
+		}
+	}
+}
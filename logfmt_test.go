@@ -0,0 +1,71 @@
+package zerolog_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	z "gitlab.com/tozd/go/zerolog"
+)
+
+func TestLogfmt(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "log")
+
+	var buffer bytes.Buffer
+	config := z.LoggingConfig{ //nolint:exhaustruct
+		Logging: z.Logging{ //nolint:exhaustruct
+			Console: z.Console{
+				Type:        "logfmt",
+				Level:       zerolog.DebugLevel,
+				Target:      "stdout",
+				Output:      &buffer,
+				ErrorOutput: nil,
+			},
+			File: z.File{ //nolint:exhaustruct
+				Path:   p,
+				Level:  zerolog.DebugLevel,
+				Format: "logfmt",
+			},
+			Main: z.Main{
+				Level:        zerolog.DebugLevel,
+				Slog:         false,
+				StdlogLevel:  nil,
+				StdlogCaller: false,
+			},
+		},
+	}
+	closer, errE := z.New(&config)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	t.Cleanup(func() {
+		// We might double close but we do not care.
+		_ = closer.Close()
+	})
+
+	config.Logger.Info().
+		Str("foo", "bar baz").
+		Int("n", 1).
+		Dict("d", zerolog.Dict().Str("x", "y")).
+		Strs("a", []string{"p"}).
+		Str("empty", "").
+		RawJSON("error", []byte(`{"error":"boom","x":"y","stack":[{"name":"main.f","file":"/x.go","line":10},{"name":"main.main","file":"/main.go","line":3}],"cause":{"error":"cause error"},"errors":[{"error":"joined"}]}`)).
+		Msg("hello")
+	config.Logger.Warn().Str("error", "plain").Send()
+
+	require.NoError(t, closer.Close())
+
+	content, err := os.ReadFile(filepath.Clean(p))
+	require.NoError(t, err)
+
+	for _, actual := range []string{buffer.String(), string(content)} {
+		assert.Regexp(t, `^`+
+			`time=\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{3}Z level=info message=hello foo="bar baz" n=1 d.x=y a="\[\\"p\\"\]" empty="" `+
+			`error=boom error.x=y error.stack="main.f /x.go:10" error.cause="cause error" error.errors.0=joined\n`+
+			`time=\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{3}Z level=warn error=plain\n`+
+			`$`, actual)
+	}
+}
@@ -0,0 +1,195 @@
+package zerolog
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+
+	"github.com/rs/zerolog"
+)
+
+// slogGroup is a group opened with SlogHandler's WithGroup
+// together with attributes added to it afterwards.
+type slogGroup struct {
+	name  string
+	attrs []slog.Attr
+}
+
+// SlogHandler is a slog.Handler which logs through a zerolog logger.
+//
+// Slog levels are mapped to zerolog levels: levels below slog.LevelDebug are mapped
+// to trace level, and other levels to the closest lower level of debug, info, warn,
+// and error. Groups are logged as nested objects and errors are marshaled
+// using zerolog.ErrorMarshalFunc (ErrorMarshalFunc when configured by New).
+//
+// If the context passed to the handler has a zerolog logger (e.g., a context
+// logger added by WithContextFunc), the record is logged through it instead, so that
+// it is buffered and flushed (triggered) together with other log entries of the
+// context logger.
+//
+// Records' time is ignored and loggers should be configured to add timestamps.
+type SlogHandler struct {
+	logger zerolog.Logger
+	attrs  []slog.Attr
+	groups []slogGroup
+}
+
+var _ slog.Handler = (*SlogHandler)(nil)
+
+// NewSlogHandler returns a new SlogHandler which logs through the logger.
+//
+// E.g., NewSlogHandler(config.Logger) returns a handler which logs through the main logger
+// configured by New.
+func NewSlogHandler(logger zerolog.Logger) *SlogHandler {
+	return &SlogHandler{
+		logger: logger,
+		attrs:  nil,
+		groups: nil,
+	}
+}
+
+// contextLogger returns the logger from the context,
+// or the handler's logger if the context does not have one.
+func (h *SlogHandler) contextLogger(ctx context.Context) *zerolog.Logger {
+	if ctx == nil {
+		return &h.logger
+	}
+	logger := zerolog.Ctx(ctx)
+	// zerolog.Ctx returns the same default logger for all contexts without a logger.
+	if logger == zerolog.Ctx(context.Background()) {
+		return &h.logger
+	}
+	return logger
+}
+
+// slogLevel maps the slog level to the zerolog level.
+func slogLevel(level slog.Level) zerolog.Level {
+	switch {
+	case level < slog.LevelDebug:
+		return zerolog.TraceLevel
+	case level < slog.LevelInfo:
+		return zerolog.DebugLevel
+	case level < slog.LevelWarn:
+		return zerolog.InfoLevel
+	case level < slog.LevelError:
+		return zerolog.WarnLevel
+	default:
+		return zerolog.ErrorLevel
+	}
+}
+
+// slogValue converts the resolved slog value into a value which zerolog's Event.Fields can log.
+func slogValue(value slog.Value) interface{} {
+	switch value.Kind() { //nolint:exhaustive
+	case slog.KindString:
+		return value.String()
+	case slog.KindInt64:
+		return value.Int64()
+	case slog.KindUint64:
+		return value.Uint64()
+	case slog.KindFloat64:
+		return value.Float64()
+	case slog.KindBool:
+		return value.Bool()
+	case slog.KindDuration:
+		return value.Duration()
+	case slog.KindTime:
+		return value.Time()
+	default:
+		return value.Any()
+	}
+}
+
+// appendSlogAttrs appends slog attributes as fields, following slog.Handler rules:
+// empty attributes and empty groups are ignored, and attributes of groups
+// with an empty key are inlined.
+func appendSlogAttrs(e *zerolog.Event, attrs []slog.Attr) *zerolog.Event {
+	for _, attr := range attrs {
+		attr.Value = attr.Value.Resolve()
+		if attr.Equal(slog.Attr{}) { //nolint:exhaustruct
+			continue
+		}
+		if attr.Value.Kind() == slog.KindGroup {
+			group := attr.Value.Group()
+			if len(group) == 0 {
+				continue
+			}
+			if attr.Key == "" {
+				e = appendSlogAttrs(e, group)
+			} else {
+				e = e.Dict(attr.Key, appendSlogAttrs(zerolog.Dict(), group))
+			}
+			continue
+		}
+		e = e.Fields([]interface{}{attr.Key, slogValue(attr.Value)})
+	}
+	return e
+}
+
+// Enabled implements slog.Handler interface for SlogHandler.
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	l := slogLevel(level)
+	return l >= h.contextLogger(ctx).GetLevel() && l >= zerolog.GlobalLevel()
+}
+
+// Handle implements slog.Handler interface for SlogHandler.
+func (h *SlogHandler) Handle(ctx context.Context, record slog.Record) error {
+	event := h.contextLogger(ctx).WithLevel(slogLevel(record.Level))
+	if event == nil {
+		return nil
+	}
+
+	attrs := make([]slog.Attr, 0, record.NumAttrs())
+	record.Attrs(func(attr slog.Attr) bool {
+		attrs = append(attrs, attr)
+		return true
+	})
+
+	// Record's attributes belong to the innermost group, so we build
+	// nested groups from the innermost one outwards.
+	for i := len(h.groups) - 1; i >= 0; i-- {
+		g := h.groups[i]
+		attrs = append(slices.Clip(g.attrs), attrs...)
+		// Empty groups are ignored.
+		if len(attrs) > 0 {
+			attrs = []slog.Attr{{Key: g.name, Value: slog.GroupValue(attrs...)}}
+		}
+	}
+
+	appendSlogAttrs(appendSlogAttrs(event, h.attrs), attrs).Msg(record.Message)
+	return nil
+}
+
+// WithAttrs implements slog.Handler interface for SlogHandler.
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	if len(h.groups) == 0 {
+		return &SlogHandler{
+			logger: h.logger,
+			attrs:  append(slices.Clip(h.attrs), attrs...),
+			groups: nil,
+		}
+	}
+	groups := slices.Clone(h.groups)
+	last := &groups[len(groups)-1]
+	last.attrs = append(slices.Clip(last.attrs), attrs...)
+	return &SlogHandler{
+		logger: h.logger,
+		attrs:  h.attrs,
+		groups: groups,
+	}
+}
+
+// WithGroup implements slog.Handler interface for SlogHandler.
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &SlogHandler{
+		logger: h.logger,
+		attrs:  h.attrs,
+		groups: append(slices.Clip(h.groups), slogGroup{name: name, attrs: nil}),
+	}
+}
@@ -0,0 +1,205 @@
+package zerolog_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"testing/slogtest"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/tozd/go/errors"
+
+	z "gitlab.com/tozd/go/zerolog"
+)
+
+func TestSlogHandler(t *testing.T) {
+	var buffer bytes.Buffer
+
+	slogtest.Run(t, func(t *testing.T) slog.Handler {
+		t.Helper()
+
+		// SlogHandler ignores records' time and the logger adds timestamps.
+		if strings.HasSuffix(t.Name(), "/zero-time") {
+			t.Skip("records' time is ignored")
+		}
+
+		buffer.Reset()
+		return z.NewSlogHandler(zerolog.New(&buffer).With().Timestamp().Logger())
+	}, func(t *testing.T) map[string]any {
+		t.Helper()
+
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &entry))
+		entry[slog.MessageKey] = entry[zerolog.MessageFieldName]
+		delete(entry, zerolog.MessageFieldName)
+		return entry
+	})
+}
+
+func TestSlogHandlerLevels(t *testing.T) {
+	for _, tt := range []struct {
+		Level    slog.Level
+		Expected string
+	}{
+		{slog.LevelDebug - 4, "trace"},
+		{slog.LevelDebug, "debug"},
+		{slog.LevelDebug + 1, "debug"},
+		{slog.LevelInfo, "info"},
+		{slog.LevelWarn, "warn"},
+		{slog.LevelWarn + 2, "warn"},
+		{slog.LevelError, "error"},
+		{slog.LevelError + 4, "error"},
+	} {
+		t.Run(tt.Level.String(), func(t *testing.T) {
+			var buffer bytes.Buffer
+			logger := slog.New(z.NewSlogHandler(zerolog.New(&buffer)))
+
+			logger.Log(t.Context(), tt.Level, "test")
+
+			assert.Equal(t, `{"level":"`+tt.Expected+`","message":"test"}`+"\n", buffer.String())
+		})
+	}
+
+	var buffer bytes.Buffer
+	handler := z.NewSlogHandler(zerolog.New(&buffer).Level(zerolog.InfoLevel))
+	assert.False(t, handler.Enabled(t.Context(), slog.LevelDebug))
+	assert.True(t, handler.Enabled(t.Context(), slog.LevelInfo))
+	slog.New(handler).Debug("test")
+	assert.Empty(t, buffer.String())
+}
+
+func TestSlogDefault(t *testing.T) {
+	defaultLogger := slog.Default()
+	t.Cleanup(func() {
+		slog.SetDefault(defaultLogger)
+	})
+
+	buffer := new(bytes.Buffer)
+	config := z.LoggingConfig{ //nolint:exhaustruct
+		Logging: z.Logging{ //nolint:exhaustruct
+			Console: z.Console{
+				Type:        "json",
+				Level:       zerolog.DebugLevel,
+				Target:      "stdout",
+				Output:      buffer,
+				ErrorOutput: nil,
+			},
+			Main: z.Main{
+				Level:        zerolog.InfoLevel,
+				Slog:         true,
+				StdlogLevel:  nil,
+				StdlogCaller: false,
+			},
+		},
+	}
+	_, errE := z.New(&config)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	logger := slog.Default().With("a", 1).WithGroup("g").With("b", true)
+
+	logger.Debug("filtered")
+	logger.Info(
+		"test",
+		"string", "foo",
+		"uint", uint64(2),
+		"float", 1.5,
+		"duration", 1500*time.Millisecond,
+		"time", time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		"error", errors.New("boom"),
+		slog.Group("nested", "c", "d"),
+		"any", map[string]int{"x": 1},
+	)
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(buffer.Bytes(), &entry))
+	assert.Contains(t, entry, "time")
+	delete(entry, "time")
+	g, ok := entry["g"].(map[string]interface{})
+	require.True(t, ok)
+	e, ok := g["error"].(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, "boom", e["error"])
+	assert.Contains(t, e, "stack")
+	delete(g, "error")
+	assert.Equal(t, map[string]interface{}{
+		"level":   "info",
+		"message": "test",
+		"a":       float64(1),
+		"g": map[string]interface{}{
+			"b":        true,
+			"string":   "foo",
+			"uint":     float64(2),
+			"float":    1.5,
+			"duration": 1.5,
+			"time":     "2026-01-02T03:04:05.000Z",
+			"nested":   map[string]interface{}{"c": "d"},
+			"any":      map[string]interface{}{"x": float64(1)},
+		},
+	}, entry)
+}
+
+func TestSlogHandlerContext(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	config := z.LoggingConfig{ //nolint:exhaustruct
+		Logging: z.Logging{ //nolint:exhaustruct
+			Console: z.Console{
+				Type:        "json",
+				Level:       zerolog.DebugLevel,
+				Target:      "stdout",
+				Output:      buffer,
+				ErrorOutput: nil,
+			},
+			Main: z.Main{
+				Level:        zerolog.InfoLevel,
+				Slog:         false,
+				StdlogLevel:  nil,
+				StdlogCaller: false,
+			},
+			Context: z.Context{
+				Level:             zerolog.DebugLevel,
+				ConditionalLevel:  zerolog.DebugLevel,
+				TriggerLevel:      zerolog.ErrorLevel,
+				MaxEntries:        0,
+				MaxBytes:          0,
+				Summary:           false,
+				SummaryLevel:      zerolog.InfoLevel,
+				TriggerOnDeadline: false,
+				TriggerOnDone:     nil,
+				Triggers:          nil,
+			},
+		},
+	}
+	_, errE := z.New(&config)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	logger := slog.New(z.NewSlogHandler(config.Logger)).With("a", 1)
+
+	ctx, closeCtx, _ := config.WithContext(t.Context())
+	defer closeCtx()
+	zerolog.Ctx(ctx).UpdateContext(func(c zerolog.Context) zerolog.Context {
+		return c.Str("requestId", "abc")
+	})
+
+	// Without a context logger, the main logger is used.
+	logger.Debug("filtered")
+	logger.Info("main")
+
+	// Debug entries are buffered by the context logger even if the main logger's level is info.
+	assert.True(t, logger.Handler().Enabled(ctx, slog.LevelDebug))
+	logger.DebugContext(ctx, "buffered")
+	assert.Regexp(t, `^\{"level":"info","a":1,"time":"[^"]+","message":"main"\}\n$`, buffer.String())
+
+	logger.ErrorContext(ctx, "trigger")
+	assert.Regexp(
+		t,
+		`^\{"level":"info","a":1,"time":"[^"]+","message":"main"\}\n`+
+			`\{"level":"debug","requestId":"abc","a":1,"time":"[^"]+","message":"buffered"\}\n`+
+			`\{"level":"error","requestId":"abc","a":1,"time":"[^"]+","message":"trigger"\}\n$`,
+		buffer.String(),
+	)
+}
@@ -0,0 +1,139 @@
+package zerolog
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// Maximum length of a level name in a prefix.
+const maxStdlogLevelLength = 8
+
+// stdlogLevels maps level names used in prefixes to zerolog levels.
+var stdlogLevels = map[string]zerolog.Level{ //nolint:gochecknoglobals
+	"trace":    zerolog.TraceLevel,
+	"debug":    zerolog.DebugLevel,
+	"info":     zerolog.InfoLevel,
+	"notice":   zerolog.InfoLevel,
+	"warn":     zerolog.WarnLevel,
+	"warning":  zerolog.WarnLevel,
+	"err":      zerolog.ErrorLevel,
+	"error":    zerolog.ErrorLevel,
+	"crit":     zerolog.ErrorLevel,
+	"critical": zerolog.ErrorLevel,
+	"fatal":    zerolog.FatalLevel,
+	"panic":    zerolog.PanicLevel,
+}
+
+// stdlogMessages maps prefixes of well-known messages logged by the standard
+// library to zerolog levels. Those prefixes are not stripped from messages.
+var stdlogMessages = []struct { //nolint:gochecknoglobals
+	prefix string
+	level  zerolog.Level
+}{
+	{"http: panic serving", zerolog.ErrorLevel},
+	{"http: Accept error", zerolog.ErrorLevel},
+	{"http: TLS handshake error", zerolog.WarnLevel},
+	{"http2: panic serving", zerolog.ErrorLevel},
+}
+
+// stdlogLevel returns the level for the level name.
+func stdlogLevel(name string) (zerolog.Level, bool) {
+	if name == "" || len(name) > maxStdlogLevelLength {
+		return zerolog.NoLevel, false
+	}
+	level, ok := stdlogLevels[strings.ToLower(name)]
+	return level, ok
+}
+
+// parseStdlogMessage returns the level of the message and the message without the level prefix.
+//
+// It recognizes "LEVEL:" and "[LEVEL]" prefixes, "level=LEVEL" logfmt fields,
+// and well-known messages logged by the standard library.
+func parseStdlogMessage(message string) (zerolog.Level, string, bool) {
+	if rest, ok := strings.CutPrefix(message, "["); ok {
+		if name, rest, ok := strings.Cut(rest, "]"); ok {
+			if level, ok := stdlogLevel(name); ok {
+				return level, strings.TrimLeft(rest, " "), true
+			}
+		}
+	}
+	if name, rest, ok := strings.Cut(message, ":"); ok {
+		if level, ok := stdlogLevel(name); ok {
+			return level, strings.TrimLeft(rest, " "), true
+		}
+	}
+	fields := strings.Split(message, " ")
+	for i, field := range fields {
+		name, ok := strings.CutPrefix(field, "level=")
+		if !ok {
+			continue
+		}
+		level, ok := stdlogLevel(strings.Trim(name, `"`))
+		if !ok {
+			continue
+		}
+		return level, strings.Join(append(fields[:i:i], fields[i+1:]...), " "), true
+	}
+	for _, m := range stdlogMessages {
+		if strings.HasPrefix(message, m.prefix) {
+			return m.level, message, true
+		}
+	}
+	return zerolog.NoLevel, message, false
+}
+
+// stdlogWriter is used as the output of Go's standard log package.
+//
+// It parses the level from the log entry's message (see parseStdlogMessage),
+// strips the level prefix, and logs the message through Logger at that level,
+// or at Level if the message does not have a recognized level.
+//
+// If Caller is set, the caller of the standard log package's function
+// is added to the log entry.
+type stdlogWriter struct {
+	Logger zerolog.Logger
+	Level  zerolog.Level
+	Caller bool
+}
+
+// Write implements io.Writer interface for stdlogWriter.
+func (w *stdlogWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	message := string(bytes.TrimSuffix(p, []byte("\n")))
+	level, message, ok := parseStdlogMessage(message)
+	if !ok {
+		level = w.Level
+	}
+	event := w.Logger.WithLevel(level)
+	if event == nil {
+		return n, nil
+	}
+	if w.Caller {
+		if frame, ok := stdlogCaller(); ok {
+			event = event.Str(zerolog.CallerFieldName, zerolog.CallerMarshalFunc(frame.PC, frame.File, frame.Line))
+		}
+	}
+	event.Msg(message)
+	return n, nil
+}
+
+// stdlogCaller returns the first frame outside of the standard log package,
+// i.e., the caller of the standard log package's function.
+func stdlogCaller() (runtime.Frame, bool) {
+	pcs := make([]uintptr, 16) //nolint:mnd
+	// We skip runtime.Callers, stdlogCaller, and stdlogWriter.Write.
+	n := runtime.Callers(3, pcs) //nolint:mnd
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "log.") {
+			return frame, true
+		}
+		if !more {
+			return runtime.Frame{}, false //nolint:exhaustruct
+		}
+	}
+}
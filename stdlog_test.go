@@ -0,0 +1,144 @@
+package zerolog_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	stdlog "log"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	z "gitlab.com/tozd/go/zerolog"
+)
+
+func newStdlogLogging(t *testing.T, buffer *bytes.Buffer, level *zerolog.Level, caller bool) {
+	t.Helper()
+
+	config := z.LoggingConfig{ //nolint:exhaustruct
+		Logging: z.Logging{ //nolint:exhaustruct
+			Console: z.Console{
+				Type:        "json",
+				Level:       zerolog.TraceLevel,
+				Target:      "stdout",
+				Output:      buffer,
+				ErrorOutput: nil,
+			},
+			Main: z.Main{
+				Level:        zerolog.TraceLevel,
+				Slog:         false,
+				StdlogLevel:  level,
+				StdlogCaller: caller,
+			},
+		},
+	}
+	_, errE := z.New(&config)
+	require.NoError(t, errE, "% -+#.1v", errE)
+}
+
+func TestStdlog(t *testing.T) {
+	for k, tt := range []struct {
+		Message  string
+		Level    string
+		Expected string
+	}{
+		// Without a recognized level prefix, log entries do not have a level by default.
+		{"plain message", "", "plain message"},
+		{"ERROR: something failed", "error", "something failed"},
+		{"error:something failed", "error", "something failed"},
+		{"Warning: deprecated", "warn", "deprecated"},
+		{"[WARN] slow query", "warn", "slow query"},
+		{"[debug]details", "debug", "details"},
+		{"level=debug msg=details", "debug", "msg=details"},
+		{`time=now level="error" msg=failed`, "error", "time=now msg=failed"},
+		{"http: panic serving 127.0.0.1:1234: boom", "error", "http: panic serving 127.0.0.1:1234: boom"},
+		{"http: TLS handshake error from 127.0.0.1:1234: EOF", "warn", "http: TLS handshake error from 127.0.0.1:1234: EOF"},
+		{"fatal: exiting", "fatal", "exiting"},
+		// Unknown prefixes are not stripped.
+		{"[main] starting", "", "[main] starting"},
+		{"server: listening", "", "server: listening"},
+		{"level=verbose msg=details", "", "level=verbose msg=details"},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			buffer := new(bytes.Buffer)
+			newStdlogLogging(t, buffer, nil, false)
+
+			stdlog.Print(tt.Message)
+
+			var entry map[string]interface{}
+			require.NoError(t, json.Unmarshal(buffer.Bytes(), &entry))
+			if tt.Level == "" {
+				assert.NotContains(t, entry, "level")
+			} else {
+				assert.Equal(t, tt.Level, entry["level"])
+			}
+			assert.Equal(t, tt.Expected, entry["message"])
+		})
+	}
+}
+
+func TestStdlogDefaultLevel(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	level := zerolog.DebugLevel
+	newStdlogLogging(t, buffer, &level, true)
+
+	stdlog.Println("plain message")
+	stdlog.Printf("[ERROR] %s", "failed")
+
+	assert.Regexp(
+		t,
+		`^\{"level":"debug","caller":"[^"]*/stdlog_test.go:\d+","time":"[^"]+","message":"plain message"\}\n`+
+			`\{"level":"error","caller":"[^"]*/stdlog_test.go:\d+","time":"[^"]+","message":"failed"\}\n$`,
+		buffer.String(),
+	)
+}
+
+func TestUnmarshalMain(t *testing.T) {
+	warnLevel := zerolog.WarnLevel
+
+	for _, tt := range []struct {
+		JSON     string
+		YAML     string
+		Expected z.Main
+	}{
+		{
+			`{"level":"debug"}`,
+			"level: debug\n",
+			z.Main{Level: zerolog.DebugLevel, Slog: false, StdlogLevel: nil, StdlogCaller: false},
+		},
+		{
+			`{"level":"debug","slog":true,"stdlogLevel":"warn","stdlogCaller":true}`,
+			"level: debug\nslog: true\nstdlogLevel: warn\nstdlogCaller: true\n",
+			z.Main{Level: zerolog.DebugLevel, Slog: true, StdlogLevel: &warnLevel, StdlogCaller: true},
+		},
+	} {
+		t.Run(tt.JSON, func(t *testing.T) {
+			var fromJSON z.Main
+			err := json.Unmarshal([]byte(tt.JSON), &fromJSON)
+			require.NoError(t, err)
+			assert.Equal(t, tt.Expected, fromJSON)
+
+			var fromYAML z.Main
+			err = yaml.Unmarshal([]byte(tt.YAML), &fromYAML)
+			require.NoError(t, err)
+			assert.Equal(t, tt.Expected, fromYAML)
+		})
+	}
+}
+
+func TestKongStdlogLevel(t *testing.T) {
+	config, _, _, err := createKong(t, false, []string{})
+	require.NoError(t, err)
+	assert.Nil(t, config.Logging.Main.StdlogLevel)
+
+	config, _, _, err = createKong(t, false, []string{"--logging.main.stdlog-level=warn"})
+	require.NoError(t, err)
+	require.NotNil(t, config.Logging.Main.StdlogLevel)
+	assert.Equal(t, zerolog.WarnLevel, *config.Logging.Main.StdlogLevel)
+
+	_, _, _, err = createKong(t, true, []string{"--logging.main.stdlog-level=disabled"})
+	assert.Error(t, err)
+}
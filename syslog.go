@@ -0,0 +1,427 @@
+package zerolog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"gitlab.com/tozd/go/errors"
+)
+
+const (
+	defaultSyslogPort = "514"
+	// Structured data ID used when rendering log entry's fields as
+	// RFC 5424 structured data. 32473 is the private enterprise number
+	// reserved for documentation and examples.
+	syslogStructuredDataID = "zerolog@32473"
+	// RFC 5424 limits APP-NAME to 48 characters.
+	syslogMaxAppName = 48
+	// RFC 5424 limits PARAM-NAME to 32 characters.
+	syslogMaxParamName = 32
+	// Timeout for connecting to the syslog server and for sending a message.
+	syslogTimeout = 5 * time.Second
+	// Initial and maximum time to wait after a failed connection before
+	// connecting to the syslog server again.
+	syslogMinBackoff = 100 * time.Millisecond
+	syslogMaxBackoff = 30 * time.Second
+)
+
+// Syslog facilities by name.
+//
+//nolint:gochecknoglobals,mnd
+var syslogFacilities = map[string]int{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+// syslogParamValueReplacer escapes characters which have to be escaped
+// in RFC 5424 structured data parameter values.
+//
+//nolint:gochecknoglobals
+var syslogParamValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// Syslog severities.
+const (
+	syslogSeverityEmergency = iota
+	syslogSeverityAlert
+	syslogSeverityCritical
+	syslogSeverityError
+	syslogSeverityWarning
+	syslogSeverityNotice
+	syslogSeverityInfo
+	syslogSeverityDebug
+)
+
+// syslogSeverity maps zerolog levels to syslog severities.
+//
+// Log entries without a level are mapped to the informational severity.
+func syslogSeverity(level zerolog.Level) int {
+	switch level { //nolint:exhaustive
+	case zerolog.TraceLevel, zerolog.DebugLevel:
+		return syslogSeverityDebug
+	case zerolog.InfoLevel:
+		return syslogSeverityInfo
+	case zerolog.WarnLevel:
+		return syslogSeverityWarning
+	case zerolog.ErrorLevel:
+		return syslogSeverityError
+	case zerolog.FatalLevel:
+		return syslogSeverityCritical
+	case zerolog.PanicLevel:
+		return syslogSeverityAlert
+	default:
+		return syslogSeverityInfo
+	}
+}
+
+// SyslogWriter sends log entries to a syslog server over UDP, TCP,
+// or a unix socket, using RFC 5424 or RFC 3164 protocol.
+//
+// Zerolog levels are mapped to syslog severities. The payload is either
+// the whole JSON log entry or just the log entry's message, in which case other
+// fields of the log entry are rendered as structured data.
+//
+// SyslogWriter connects to the syslog server on the first write.
+// If sending fails, SyslogWriter reconnects and tries again once.
+// After a failed connection, log entries are dropped (and an error
+// is returned) without connecting again until an exponentially
+// increasing backoff (up to 30 seconds) passes.
+//
+// It is safe for concurrent use.
+type SyslogWriter struct {
+	network  string
+	address  string
+	facility int
+	appName  string
+	hostname string
+	pid      int
+	rfc3164  bool
+	message  bool
+
+	mu      sync.Mutex
+	conn    net.Conn
+	closed  bool
+	backoff time.Duration
+	retryAt time.Time
+}
+
+// NewSyslogWriter returns a SyslogWriter sending log entries to the configured address.
+//
+// It does not connect to the address, so the syslog server does not have to be
+// available yet. Connection errors are returned from writes.
+func NewSyslogWriter(config Syslog) (*SyslogWriter, errors.E) {
+	u, err := url.Parse(config.Address)
+	if err != nil {
+		errE := errors.WithMessage(err, "invalid syslog address")
+		errors.Details(errE)["value"] = config.Address
+		return nil, errE
+	}
+	var address string
+	switch u.Scheme {
+	case "udp", "tcp":
+		address = u.Host
+		if u.Port() == "" {
+			address = net.JoinHostPort(u.Hostname(), defaultSyslogPort)
+		}
+	case "unix", "unixgram":
+		address = u.Path
+	default:
+		errE := errors.New("invalid syslog address scheme")
+		errors.Details(errE)["value"] = config.Address
+		return nil, errE
+	}
+
+	facility := syslogFacilities["user"]
+	if config.Facility != "" {
+		f, ok := syslogFacilities[config.Facility]
+		if !ok {
+			errE := errors.New("invalid syslog facility")
+			errors.Details(errE)["value"] = config.Facility
+			return nil, errE
+		}
+		facility = f
+	}
+
+	var rfc3164 bool
+	switch config.Protocol {
+	case "", "rfc5424":
+	case "rfc3164":
+		rfc3164 = true
+	default:
+		errE := errors.New("invalid syslog protocol")
+		errors.Details(errE)["value"] = config.Protocol
+		return nil, errE
+	}
+
+	var message bool
+	switch config.Payload {
+	case "", "json":
+	case "message":
+		message = true
+	default:
+		errE := errors.New("invalid syslog payload")
+		errors.Details(errE)["value"] = config.Payload
+		return nil, errE
+	}
+
+	appName := config.AppName
+	if appName == "" {
+		appName = filepath.Base(os.Args[0])
+	}
+
+	hostname, _ := os.Hostname()
+
+	w := &SyslogWriter{
+		network:  u.Scheme,
+		address:  address,
+		facility: facility,
+		appName:  sanitizeSyslogName(appName, syslogMaxAppName),
+		hostname: sanitizeSyslogName(hostname, 255), //nolint:mnd
+		pid:      os.Getpid(),
+		rfc3164:  rfc3164,
+		message:  message,
+		mu:       sync.Mutex{},
+		conn:     nil,
+		closed:   false,
+		backoff:  0,
+		retryAt:  time.Time{},
+	}
+
+	return w, nil
+}
+
+// sanitizeSyslogName replaces characters not allowed in syslog header
+// names (and structured data parameter names) and truncates the name.
+func sanitizeSyslogName(name string, maxLength int) string {
+	b := []byte(name)
+	for i, c := range b {
+		if c <= ' ' || c > '~' || c == '=' || c == ']' || c == '"' {
+			b[i] = '_'
+		}
+	}
+	if len(b) > maxLength {
+		b = b[:maxLength]
+	}
+	return string(b)
+}
+
+// connect connects to the syslog server.
+//
+// It expects the caller to hold the lock (or to have exclusive access).
+func (w *SyslogWriter) connect() errors.E {
+	conn, err := net.DialTimeout(w.network, w.address, syslogTimeout)
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot connect to syslog")
+		errors.Details(errE)["network"] = w.network
+		errors.Details(errE)["address"] = w.address
+		return errE
+	}
+	w.conn = conn
+	return nil
+}
+
+// send sends the message over the current connection.
+//
+// It expects the caller to hold the lock.
+func (w *SyslogWriter) send(msg []byte) error {
+	err := w.conn.SetWriteDeadline(time.Now().Add(syslogTimeout))
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	_, err = w.conn.Write(msg)
+	return err //nolint:wrapcheck
+}
+
+// stream returns true if the connection is stream-oriented
+// and messages have to be framed.
+func (w *SyslogWriter) stream() bool {
+	return w.network == "tcp" || w.network == "unix"
+}
+
+// format formats the log entry at the level as a syslog message.
+func (w *SyslogWriter) format(level zerolog.Level, p []byte) []byte {
+	entry := bytes.TrimSuffix(p, []byte("\n"))
+
+	timestamp := time.Now()
+	msg := string(entry)
+	sd := ""
+
+	// If the log entry is not JSON, we send it as-is.
+	fields, errE := parseEntry(entry)
+	if errE == nil {
+		if w.message {
+			msg = ""
+		}
+		params := []string{}
+		for _, field := range fields {
+			switch field.Key {
+			case zerolog.TimestampFieldName:
+				t, err := time.Parse(time.RFC3339Nano, fieldString(field.Value))
+				if err == nil {
+					timestamp = t
+				}
+			case zerolog.MessageFieldName:
+				if w.message {
+					msg = fieldString(field.Value)
+				}
+			case zerolog.LevelFieldName:
+			default:
+				if w.message {
+					value := syslogParamValueReplacer.Replace(fieldString(field.Value))
+					params = append(params, sanitizeSyslogName(field.Key, syslogMaxParamName)+`="`+value+`"`)
+				}
+			}
+		}
+		if len(params) > 0 {
+			sd = "[" + syslogStructuredDataID + " " + strings.Join(params, " ") + "]"
+		}
+	}
+
+	pri := w.facility*8 + syslogSeverity(level) //nolint:mnd
+
+	var buf bytes.Buffer
+	if w.rfc3164 {
+		// RFC 3164 does not support structured data so we append it to the message.
+		if sd != "" {
+			if msg != "" {
+				msg += " "
+			}
+			msg += sd
+		}
+		fmt.Fprintf(&buf, "<%d>%s %s %s[%d]: %s", pri, timestamp.Local().Format(time.Stamp), w.hostname, w.appName, w.pid, msg) //nolint:gosmopolitan
+	} else {
+		hostname := w.hostname
+		if hostname == "" {
+			hostname = "-"
+		}
+		if sd == "" {
+			sd = "-"
+		}
+		fmt.Fprintf(&buf, "<%d>1 %s %s %s %d - %s", pri, timestamp.UTC().Format(TimeFieldFormat), hostname, w.appName, w.pid, sd)
+		if msg != "" {
+			buf.WriteString(" ")
+			buf.WriteString(msg)
+		}
+	}
+
+	if !w.stream() {
+		return buf.Bytes()
+	}
+	if w.rfc3164 {
+		// Non-transparent framing.
+		buf.WriteString("\n")
+		return buf.Bytes()
+	}
+	// Octet counting framing.
+	return append([]byte(strconv.Itoa(buf.Len())+" "), buf.Bytes()...)
+}
+
+// Write implements io.Writer interface for SyslogWriter.
+//
+// The log entry is sent without a level.
+func (w *SyslogWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(zerolog.NoLevel, p)
+}
+
+// WriteLevel implements zerolog.LevelWriter interface for SyslogWriter.
+//
+// Each call is expected to contain one whole log entry.
+func (w *SyslogWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	msg := w.format(level, p)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, errors.WithStack(os.ErrClosed)
+	}
+
+	if w.conn != nil {
+		err := w.send(msg)
+		if err == nil {
+			return len(p), nil
+		}
+		_ = w.conn.Close()
+		w.conn = nil
+	}
+
+	if time.Now().Before(w.retryAt) {
+		// We drop the log entry instead of blocking on connecting again.
+		errE := errors.New("syslog is disconnected")
+		errors.Details(errE)["network"] = w.network
+		errors.Details(errE)["address"] = w.address
+		return 0, errE
+	}
+
+	// We (re)connect and try again.
+	errE := w.connect()
+	if errE != nil {
+		w.fail()
+		return 0, errE
+	}
+	err := w.send(msg)
+	if err != nil {
+		_ = w.conn.Close()
+		w.conn = nil
+		w.fail()
+		return 0, errors.WithMessage(err, "cannot send to syslog")
+	}
+	w.backoff = 0
+	w.retryAt = time.Time{}
+	return len(p), nil
+}
+
+// fail increases the backoff after a failed connection and
+// sets when to connect again.
+//
+// It expects the caller to hold the lock.
+func (w *SyslogWriter) fail() {
+	w.backoff = min(max(2*w.backoff, syslogMinBackoff), syslogMaxBackoff)
+	w.retryAt = time.Now().Add(w.backoff)
+}
+
+// Close implements io.Closer interface for SyslogWriter.
+func (w *SyslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.closed = true
+	if w.conn == nil {
+		return nil
+	}
+
+	err := w.conn.Close()
+	w.conn = nil
+	return errors.WithStack(err)
+}
+
+var _ zerolog.LevelWriter = (*SyslogWriter)(nil)
+
+var _ io.Closer = (*SyslogWriter)(nil)
@@ -0,0 +1,334 @@
+package zerolog_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	z "gitlab.com/tozd/go/zerolog"
+)
+
+const testSyslogEntry = `{"level":"info","time":"2026-01-02T03:04:05.678Z","foo":"b\"a]r","n":1,"message":"hello"}` + "\n"
+
+func syslogHostname(t *testing.T) string {
+	t.Helper()
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+	return hostname
+}
+
+func TestSyslogWriterUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	w, errE := z.NewSyslogWriter(z.Syslog{ //nolint:exhaustruct
+		Address:  "udp://" + conn.LocalAddr().String(),
+		Facility: "local0",
+		AppName:  "test",
+	})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	t.Cleanup(func() {
+		// We might double close but we do not care.
+		_ = w.Close()
+	})
+
+	for _, level := range []zerolog.Level{zerolog.DebugLevel, zerolog.InfoLevel, zerolog.WarnLevel, zerolog.ErrorLevel, zerolog.NoLevel} {
+		_, err = w.WriteLevel(level, []byte(testSyslogEntry))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	_, err = w.Write([]byte(testSyslogEntry))
+	assert.ErrorIs(t, err, os.ErrClosed)
+
+	prefix := fmt.Sprintf(" 2026-01-02T03:04:05.678Z %s test %d - - %s", syslogHostname(t), os.Getpid(), strings.TrimSuffix(testSyslogEntry, "\n"))
+	// Facility local0 is 16 and levels are mapped to severities 7, 6, 4, 3, and 6.
+	for _, pri := range []int{135, 134, 132, 131, 134} {
+		buf := make([]byte, 1024)
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("<%d>1", pri)+prefix, string(buf[:n]))
+	}
+}
+
+func TestSyslogWriterTCP(t *testing.T) {
+	timestamp := time.Date(2026, 1, 2, 3, 4, 5, 678000000, time.UTC)
+
+	for _, tt := range []struct {
+		Protocol string
+		Payload  string
+		Expected func(hostname string, pid int) string
+	}{
+		{"rfc5424", "json", func(hostname string, pid int) string {
+			m := fmt.Sprintf("<14>1 2026-01-02T03:04:05.678Z %s test %d - - %s", hostname, pid, strings.TrimSuffix(testSyslogEntry, "\n"))
+			return strconv.Itoa(len(m)) + " " + m
+		}},
+		{"rfc5424", "message", func(hostname string, pid int) string {
+			m := fmt.Sprintf(`<14>1 2026-01-02T03:04:05.678Z %s test %d - [zerolog@32473 foo="b\"a\]r" n="1"] hello`, hostname, pid)
+			return strconv.Itoa(len(m)) + " " + m
+		}},
+		{"rfc3164", "json", func(hostname string, pid int) string {
+			return fmt.Sprintf("<14>%s %s test[%d]: %s", timestamp.Local().Format(time.Stamp), hostname, pid, testSyslogEntry)
+		}},
+		{"rfc3164", "message", func(hostname string, pid int) string {
+			return fmt.Sprintf(`<14>%s %s test[%d]: hello [zerolog@32473 foo="b\"a\]r" n="1"]`+"\n", timestamp.Local().Format(time.Stamp), hostname, pid)
+		}},
+	} {
+		t.Run(tt.Protocol+"/"+tt.Payload, func(t *testing.T) {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				_ = listener.Close()
+			})
+
+			received := make(chan string, 1)
+			go func() {
+				conn, err := listener.Accept()
+				if err != nil {
+					received <- err.Error()
+					return
+				}
+				defer conn.Close()
+				data, _ := io.ReadAll(conn)
+				received <- string(data)
+			}()
+
+			w, errE := z.NewSyslogWriter(z.Syslog{ //nolint:exhaustruct
+				Address:  "tcp://" + listener.Addr().String(),
+				AppName:  "test",
+				Protocol: tt.Protocol,
+				Payload:  tt.Payload,
+			})
+			require.NoError(t, errE, "% -+#.1v", errE)
+			t.Cleanup(func() {
+				// We might double close but we do not care.
+				_ = w.Close()
+			})
+
+			_, err = w.WriteLevel(zerolog.InfoLevel, []byte(testSyslogEntry))
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+
+			assert.Equal(t, tt.Expected(syslogHostname(t), os.Getpid()), <-received)
+		})
+	}
+}
+
+func TestSyslogWriterReconnect(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+
+	received := make(chan string, 10)
+	go func() {
+		for i := 0; ; i++ {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					received <- scanner.Text()
+					if i == 0 {
+						// We close the first connection after the first message.
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	w, errE := z.NewSyslogWriter(z.Syslog{ //nolint:exhaustruct
+		Address:  "tcp://" + listener.Addr().String(),
+		AppName:  "test",
+		Protocol: "rfc3164",
+		Payload:  "message",
+	})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	t.Cleanup(func() {
+		_ = w.Close()
+	})
+
+	_, err = w.Write([]byte(`{"message":"first"}`))
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(<-received, ": first"))
+
+	// Writes to a closed connection eventually fail and the writer reconnects.
+	assert.Eventually(t, func() bool {
+		_, err := w.Write([]byte(`{"message":"second"}`))
+		if err != nil {
+			return false
+		}
+		select {
+		case line := <-received:
+			return strings.HasSuffix(line, ": second")
+		case <-time.After(10 * time.Millisecond):
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestSyslogWriterUnixgram(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unixgram sockets are not supported on Windows")
+	}
+
+	p := filepath.Join(t.TempDir(), "log")
+	conn, err := net.ListenPacket("unixgram", p)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	w, errE := z.NewSyslogWriter(z.Syslog{ //nolint:exhaustruct
+		Address:  "unixgram://" + p,
+		AppName:  "test",
+		Protocol: "rfc3164",
+		Payload:  "message",
+	})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	t.Cleanup(func() {
+		_ = w.Close()
+	})
+
+	_, err = w.WriteLevel(zerolog.ErrorLevel, []byte(`{"level":"error","message":"hello"}`+"\n"))
+	require.NoError(t, err)
+
+	buf := make([]byte, 1024)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.Regexp(t, fmt.Sprintf(`^<11>\w{3} [ \d]\d \d\d:\d\d:\d\d \S+ test\[%d\]: hello$`, os.Getpid()), string(buf[:n]))
+}
+
+func TestSyslogWriterInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		Config z.Syslog
+		Error  string
+	}{
+		{z.Syslog{Address: "http://localhost"}, "invalid syslog address scheme"},               //nolint:exhaustruct
+		{z.Syslog{Address: "udp://localhost", Facility: "invalid"}, "invalid syslog facility"}, //nolint:exhaustruct
+		{z.Syslog{Address: "udp://localhost", Protocol: "invalid"}, "invalid syslog protocol"}, //nolint:exhaustruct
+		{z.Syslog{Address: "udp://localhost", Payload: "invalid"}, "invalid syslog payload"},   //nolint:exhaustruct
+	} {
+		t.Run(tt.Config.Address, func(t *testing.T) {
+			_, errE := z.NewSyslogWriter(tt.Config)
+			assert.ErrorContains(t, errE, tt.Error)
+		})
+	}
+}
+
+func TestSyslogWriterLazyConnect(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unixgram sockets are not supported on Windows")
+	}
+
+	// The syslog server is not available yet.
+	p := filepath.Join(t.TempDir(), "log")
+	w, errE := z.NewSyslogWriter(z.Syslog{ //nolint:exhaustruct
+		Address:  "unixgram://" + p,
+		AppName:  "test",
+		Protocol: "rfc3164",
+		Payload:  "message",
+	})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	t.Cleanup(func() {
+		_ = w.Close()
+	})
+
+	_, err := w.Write([]byte(`{"message":"lost"}`))
+	assert.ErrorContains(t, err, "cannot connect to syslog")
+
+	conn, err := net.ListenPacket("unixgram", p)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	// Until the backoff passes, log entries are dropped.
+	_, err = w.Write([]byte(`{"message":"dropped"}`))
+	assert.ErrorContains(t, err, "syslog is disconnected")
+
+	assert.Eventually(t, func() bool {
+		_, err := w.Write([]byte(`{"message":"hello"}`))
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	buf := make([]byte, 1024)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(string(buf[:n]), ": hello"))
+}
+
+func TestSyslog(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	config := z.LoggingConfig{
+		Logger:      zerolog.Nop(),
+		WithContext: nil,
+		Logging: z.Logging{ //nolint:exhaustruct
+			Console: z.Console{
+				Type:        "disable",
+				Level:       zerolog.DebugLevel,
+				Target:      "stdout",
+				Output:      nil,
+				ErrorOutput: nil,
+			},
+			Syslog: z.Syslog{ //nolint:exhaustruct
+				Address: "udp://" + conn.LocalAddr().String(),
+				Level:   zerolog.InfoLevel,
+				AppName: "test",
+				Payload: "message",
+			},
+			Main: z.Main{
+				Level:        zerolog.DebugLevel,
+				Slog:         false,
+				StdlogLevel:  nil,
+				StdlogCaller: false,
+			},
+		},
+	}
+	closer, errE := z.New(&config)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	t.Cleanup(func() {
+		// We might double close but we do not care.
+		_ = closer.Close()
+	})
+
+	config.Logger.Debug().Msg("filtered out")
+	config.Logger.Warn().Str("foo", "bar").Msg("test")
+
+	require.NoError(t, closer.Close())
+
+	buf := make([]byte, 1024)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.Regexp(t, fmt.Sprintf(`^<12>1 \d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{3}Z \S+ test %d - \[zerolog@32473 foo="bar"\] test$`, os.Getpid()), string(buf[:n]))
+}
@@ -0,0 +1,172 @@
+package zerolog
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	// TraceParentHeader is the W3C Trace Context request header
+	// with the trace ID, parent span ID, and trace flags.
+	TraceParentHeader = "traceparent"
+
+	// TraceStateHeader is the W3C Trace Context request header
+	// with vendor-specific trace state.
+	TraceStateHeader = "tracestate"
+
+	// Sampled flag of the trace flags.
+	traceFlagSampled = 0x01
+
+	// Length of traceparent header value for version 00.
+	traceParentLength = 55
+
+	// Trace state longer than this is ignored.
+	maxTraceStateLength = 512
+)
+
+type traceContextKey struct{}
+
+// TraceContext is a W3C Trace Context (https://www.w3.org/TR/trace-context/)
+// of a context logger.
+type TraceContext struct {
+	// TraceID is the trace ID as 32 lowercase hex characters.
+	TraceID string
+
+	// ParentID is the parent span ID as 16 lowercase hex characters.
+	// It is empty when the trace has been generated.
+	ParentID string
+
+	// SpanID is the span ID (16 lowercase hex characters) generated for
+	// the context logger, as a child span of the parent span.
+	SpanID string
+
+	// Sampled is the sampled flag of the trace flags.
+	Sampled bool
+
+	// State is the value of the tracestate header, if any.
+	State string
+}
+
+// TraceParent returns the traceparent header value to propagate the trace
+// context to outgoing requests, with SpanID as the parent span ID.
+func (t TraceContext) TraceParent() string {
+	flags := "00"
+	if t.Sampled {
+		flags = "01"
+	}
+	return "00-" + t.TraceID + "-" + t.SpanID + "-" + flags
+}
+
+// Trace returns the trace context added to the context by TracedWithContext
+// or NewHandler's WithTraceContext option, if any.
+func Trace(ctx context.Context) (TraceContext, bool) {
+	trace, ok := ctx.Value(traceContextKey{}).(TraceContext)
+	return trace, ok
+}
+
+// validHex returns true if s is n lowercase hex characters and not all zeros.
+func validHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	zero := true
+	for i := range len(s) {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+		if c != '0' {
+			zero = false
+		}
+	}
+	return !zero
+}
+
+// parseTraceParent parses the traceparent header value and returns
+// if it is valid.
+//
+// Versions higher than 00 are parsed as version 00, ignoring
+// any additional fields, as required by the specification.
+func parseTraceParent(traceparent string) (TraceContext, bool) {
+	if len(traceparent) < traceParentLength || (len(traceparent) > traceParentLength && traceparent[traceParentLength] != '-') {
+		return TraceContext{}, false //nolint:exhaustruct
+	}
+	version, err := hex.DecodeString(traceparent[0:2])
+	if err != nil || strings.ToLower(traceparent[0:2]) != traceparent[0:2] || traceparent[2] != '-' {
+		return TraceContext{}, false //nolint:exhaustruct
+	}
+	if version[0] == 0xff || (version[0] == 0 && len(traceparent) != traceParentLength) {
+		return TraceContext{}, false //nolint:exhaustruct
+	}
+	traceID := traceparent[3:35]
+	parentID := traceparent[36:52]
+	if !validHex(traceID, 32) || traceparent[35] != '-' || !validHex(parentID, 16) || traceparent[52] != '-' { //nolint:mnd
+		return TraceContext{}, false //nolint:exhaustruct
+	}
+	flags, err := hex.DecodeString(traceparent[53:55])
+	if err != nil || strings.ToLower(traceparent[53:55]) != traceparent[53:55] {
+		return TraceContext{}, false //nolint:exhaustruct
+	}
+	return TraceContext{
+		TraceID:  traceID,
+		ParentID: parentID,
+		SpanID:   "",
+		Sampled:  flags[0]&traceFlagSampled != 0,
+		State:    "",
+	}, true
+}
+
+// randomHex returns n random bytes as lowercase hex characters.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// withTrace adds the trace context parsed from traceparent and tracestate to the context
+// and its context logger. If traceparent is invalid, a new trace is generated.
+//
+// If the context already has a trace context, the context is returned unchanged.
+func withTrace(ctx context.Context, traceparent, tracestate string) context.Context {
+	if _, ok := Trace(ctx); ok {
+		return ctx
+	}
+	trace, ok := parseTraceParent(strings.TrimSpace(traceparent))
+	if !ok {
+		// Trace state is ignored without a valid parent.
+		trace = TraceContext{
+			TraceID:  randomHex(16), //nolint:mnd
+			ParentID: "",
+			SpanID:   "",
+			Sampled:  false,
+			State:    "",
+		}
+	} else if len(tracestate) <= maxTraceStateLength {
+		trace.State = strings.TrimSpace(tracestate)
+	}
+	trace.SpanID = randomHex(8) //nolint:mnd
+	ctx = context.WithValue(ctx, traceContextKey{}, trace)
+	logger := zerolog.Ctx(ctx).With().Str("trace_id", trace.TraceID).Str("span_id", trace.SpanID).Bool("sampled", trace.Sampled).Logger()
+	return withContextLogger(ctx, logger)
+}
+
+// TracedWithContext returns a WithContextFunc which calls withContext and adds
+// to the context and its context logger a W3C Trace Context parsed from traceparent
+// and tracestate values (e.g., from headers or message metadata).
+//
+// All log entries of the context logger get trace_id, span_id, and sampled fields.
+// If traceparent is empty or invalid, a new trace (which is not sampled) is generated.
+// In both cases a new span ID is generated for the context logger. The trace
+// context is available from the context using Trace.
+//
+// If the context already has a trace context (e.g., when nested), it is kept.
+func TracedWithContext(withContext WithContextFunc, traceparent, tracestate string) WithContextFunc {
+	return func(ctx context.Context) (context.Context, func(), func()) {
+		ctx, closeCtx, trigger := withContext(ctx)
+		return withTrace(ctx, traceparent, tracestate), closeCtx, trigger
+	}
+}
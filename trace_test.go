@@ -0,0 +1,172 @@
+package zerolog_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	z "gitlab.com/tozd/go/zerolog"
+)
+
+func newTraceLogging(t *testing.T, buffer *bytes.Buffer) z.WithContextFunc {
+	t.Helper()
+
+	config := z.LoggingConfig{ //nolint:exhaustruct
+		Logging: z.Logging{ //nolint:exhaustruct
+			Console: z.Console{
+				Type:        "json",
+				Level:       zerolog.DebugLevel,
+				Target:      "stdout",
+				Output:      buffer,
+				ErrorOutput: nil,
+			},
+			Main: z.Main{
+				Level:        zerolog.Disabled,
+				Slog:         false,
+				StdlogLevel:  nil,
+				StdlogCaller: false,
+			},
+			Context: z.Context{
+				Level:             zerolog.DebugLevel,
+				ConditionalLevel:  zerolog.DebugLevel,
+				TriggerLevel:      zerolog.DebugLevel,
+				MaxEntries:        0,
+				MaxBytes:          0,
+				Summary:           false,
+				SummaryLevel:      zerolog.InfoLevel,
+				TriggerOnDeadline: false,
+				TriggerOnDone:     nil,
+				Triggers:          nil,
+			},
+		},
+	}
+	_, errE := z.New(&config)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	return config.WithContext
+}
+
+func TestNewHandlerTraceContext(t *testing.T) {
+	for k, tt := range []struct {
+		TraceParent string
+		TraceState  []string
+		TraceID     string
+		ParentID    string
+		Sampled     bool
+		State       string
+	}{
+		{"", nil, "", "", false, ""},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", []string{"congo=t61rcWkgMzE"}, "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", true, "congo=t61rcWkgMzE"},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", []string{"congo=t61rcWkgMzE", "rojo=00f067aa0ba902b7"}, "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", false, "congo=t61rcWkgMzE,rojo=00f067aa0ba902b7"},
+		// Future versions can have additional fields.
+		{"cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-09-extra", nil, "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", true, ""},
+		// Invalid values, for which a new trace is generated and trace state ignored.
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", []string{"congo=t61rcWkgMzE"}, "", "", false, ""},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", nil, "", "", false, ""},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", nil, "", "", false, ""},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", nil, "", "", false, ""},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", nil, "", "", false, ""},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0x", nil, "", "", false, ""},
+		{"00_4bf92f3577b34da6a3ce929d0e0e4736_00f067aa0ba902b7_01", nil, "", "", false, ""},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			buffer := new(bytes.Buffer)
+			withContext := newTraceLogging(t, buffer)
+
+			var trace z.TraceContext
+			handler := http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+				var ok bool
+				trace, ok = z.Trace(req.Context())
+				assert.True(t, ok)
+				zerolog.Ctx(req.Context()).Debug().Msg("test")
+			})
+			// Nesting NewHandler does not change the trace context.
+			h := z.NewHandler(withContext, z.WithTraceContext())(z.NewHandler(withContext, z.WithTraceContext())(handler))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.TraceParent != "" {
+				req.Header.Set("traceparent", tt.TraceParent)
+			}
+			for _, state := range tt.TraceState {
+				req.Header.Add("tracestate", state)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if tt.TraceID != "" {
+				assert.Equal(t, tt.TraceID, trace.TraceID)
+			} else {
+				assert.Regexp(t, `^[0-9a-f]{32}$`, trace.TraceID)
+				assert.NotEqual(t, "4bf92f3577b34da6a3ce929d0e0e4736", trace.TraceID)
+			}
+			assert.Equal(t, tt.ParentID, trace.ParentID)
+			assert.Regexp(t, `^[0-9a-f]{16}$`, trace.SpanID)
+			assert.NotEqual(t, trace.ParentID, trace.SpanID)
+			assert.Equal(t, tt.Sampled, trace.Sampled)
+			assert.Equal(t, tt.State, trace.State)
+
+			flags := "00"
+			if tt.Sampled {
+				flags = "01"
+			}
+			assert.Equal(t, "00-"+trace.TraceID+"-"+trace.SpanID+"-"+flags, trace.TraceParent())
+
+			var entry map[string]interface{}
+			require.NoError(t, json.Unmarshal(buffer.Bytes(), &entry))
+			assert.Equal(t, trace.TraceID, entry["trace_id"])
+			assert.Equal(t, trace.SpanID, entry["span_id"])
+			assert.Equal(t, trace.Sampled, entry["sampled"])
+		})
+	}
+}
+
+func TestTracedWithContext(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	withContext := newTraceLogging(t, buffer)
+
+	ctx, closeCtx, _ := z.TracedWithContext(withContext, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "")(context.Background())
+	defer closeCtx()
+
+	trace, ok := z.Trace(ctx)
+	require.True(t, ok)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", trace.TraceID)
+
+	// Nested context logger keeps the trace context.
+	nestedCtx, nestedCloseCtx, _ := z.TracedWithContext(withContext, "", "")(ctx)
+	defer nestedCloseCtx()
+
+	nestedTrace, ok := z.Trace(nestedCtx)
+	require.True(t, ok)
+	assert.Equal(t, trace, nestedTrace)
+
+	zerolog.Ctx(nestedCtx).Debug().Msg("test")
+
+	assert.Regexp(t, `^\{"level":"debug","trace_id":"4bf92f3577b34da6a3ce929d0e0e4736","span_id":"`+regexp.QuoteMeta(trace.SpanID)+`","sampled":true,"time":"[^"]+","message":"test"\}\n$`, buffer.String())
+}
+
+func TestTracedWithContextParentLogger(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	withContext := newTraceLogging(t, buffer)
+
+	ctx, closeCtx, _ := withContext(context.Background())
+	defer closeCtx()
+
+	tracedCtx, _, _ := z.TracedWithContext(func(ctx context.Context) (context.Context, func(), func()) {
+		return ctx, func() {}, func() {}
+	}, "", "")(ctx)
+
+	_, ok := z.Trace(tracedCtx)
+	require.True(t, ok)
+
+	// The parent's context logger does not get trace context fields.
+	zerolog.Ctx(ctx).Debug().Msg("test")
+
+	assert.Regexp(t, `^\{"level":"debug","time":"[^"]+","message":"test"\}\n$`, buffer.String())
+}
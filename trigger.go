@@ -0,0 +1,101 @@
+package zerolog
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/rs/zerolog"
+)
+
+// TriggerEntry is a log entry written through a context logger,
+// as passed to trigger functions.
+type TriggerEntry struct {
+	// Level of the log entry.
+	Level zerolog.Level
+
+	// Data is the log entry as written by zerolog (usually JSON).
+	Data []byte
+
+	// Counts is the number of log entries written through the context
+	// logger so far (including this log entry) per level.
+	// It must not be modified.
+	Counts map[zerolog.Level]int
+
+	fields []entryField
+}
+
+// Field returns the value of the log entry's top-level field as JSON,
+// and if the field exists at all.
+func (e TriggerEntry) Field(key string) (json.RawMessage, bool) {
+	for _, field := range e.fields {
+		if field.Key == key {
+			return field.Value, true
+		}
+	}
+	return nil, false
+}
+
+// TriggerFunc is called for every log entry written through a context logger
+// until it is triggered. If it returns true, buffered log entries are flushed
+// (triggered) and all further log entries pass through.
+//
+// It is called while the context logger is locked, so it must not log
+// through the same context logger.
+type TriggerFunc func(entry TriggerEntry) bool
+
+// TriggerOnField returns a TriggerFunc which triggers on a log entry with
+// the top-level field, regardless of its level.
+//
+// E.g., TriggerOnField(zerolog.ErrorFieldName) triggers on any log entry with an error.
+func TriggerOnField(key string) TriggerFunc {
+	return func(entry TriggerEntry) bool {
+		_, ok := entry.Field(key)
+		return ok
+	}
+}
+
+// TriggerOnFieldValue returns a TriggerFunc which triggers on a log entry with
+// the top-level field equal to the value, regardless of its level.
+//
+// Values are compared after they are marshaled into JSON, so, e.g.,
+// numbers of different types compare equal.
+func TriggerOnFieldValue(key string, value interface{}) TriggerFunc {
+	var expected interface{}
+	data, err := json.Marshal(value)
+	if err != nil {
+		// Value cannot be logged, so no log entry can match it.
+		return func(TriggerEntry) bool {
+			return false
+		}
+	}
+	_ = json.Unmarshal(data, &expected)
+
+	return func(entry TriggerEntry) bool {
+		field, ok := entry.Field(key)
+		if !ok {
+			return false
+		}
+		var actual interface{}
+		err := json.Unmarshal(field, &actual)
+		if err != nil {
+			return false
+		}
+		return reflect.DeepEqual(expected, actual)
+	}
+}
+
+// TriggerOnCount returns a TriggerFunc which triggers once n log entries
+// at the level or higher have been written through the context logger.
+//
+// E.g., TriggerOnCount(zerolog.WarnLevel, 3) triggers on the third warning.
+func TriggerOnCount(level zerolog.Level, n int) TriggerFunc {
+	return func(entry TriggerEntry) bool {
+		count := 0
+		for l, c := range entry.Counts {
+			if l >= level && l < zerolog.NoLevel {
+				count += c
+			}
+		}
+		return count >= n
+	}
+}
@@ -13,7 +13,7 @@ import (
 	"fmt"
 	"io"
 	stdlog "log"
-	"net/http"
+	"log/slog"
 	"os"
 	"reflect"
 	"strconv"
@@ -21,8 +21,8 @@ import (
 	"time"
 
 	"github.com/alecthomas/kong"
-	"github.com/felixge/httpsnoop"
 	"github.com/goccy/go-yaml"
+	"github.com/mattn/go-isatty"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"gitlab.com/tozd/go/errors"
@@ -45,22 +45,30 @@ const (
 //	kong.Vars{
 //		"defaultLoggingConsoleType":             DefaultConsoleType,
 //		"defaultLoggingConsoleLevel":            DefaultConsoleLevel,
+//		"defaultLoggingConsoleTarget":           DefaultConsoleTarget,
 //		"defaultLoggingFileLevel":               DefaultFileLevel,
+//		"defaultLoggingSyslogLevel":             DefaultSyslogLevel,
+//		"defaultLoggingJournaldLevel":           DefaultJournaldLevel,
 //		"defaultLoggingMainLevel":               DefaultMainLevel,
 //		"defaultLoggingContextLevel":            DefaultContextLevel,
 //		"defaultLoggingContextConditionalLevel": DefaultContextConditionalLevel,
 //		"defaultLoggingContextTriggerLevel":     DefaultContextTriggerLevel,
+//		"defaultLoggingContextSummaryLevel":     DefaultContextSummaryLevel,
 //	}
 //
 // [Kong]: https://github.com/alecthomas/kong
 const (
 	DefaultConsoleType             = "color"
 	DefaultConsoleLevel            = "debug"
+	DefaultConsoleTarget           = "stdout"
 	DefaultFileLevel               = "debug"
+	DefaultSyslogLevel             = "info"
+	DefaultJournaldLevel           = "info"
 	DefaultMainLevel               = "info"
 	DefaultContextLevel            = "debug"
 	DefaultContextConditionalLevel = "debug"
 	DefaultContextTriggerLevel     = "error"
+	DefaultContextSummaryLevel     = "info"
 )
 
 // TimeFieldFormat is the format for timestamps in log entries.
@@ -68,25 +76,37 @@ const TimeFieldFormat = "2006-01-02T15:04:05.000Z07:00"
 
 // Console is configuration of logging log entries to the console (stdout by default).
 //
-// Type can be the following values: color (human-friendly formatted and colorized),
-// nocolor (just human-friendly formatted), json, disable (do not log to the console).
+// Target can be stdout, stderr, or split. With split, log entries at warn level
+// and above are written to stderr while all other log entries to stdout.
+//
+// Type can be the following values: auto (detect from the environment), color
+// (human-friendly formatted and colorized), nocolor (just human-friendly formatted),
+// json, logfmt, disable (do not log to the console).
+//
+// With auto, log entries are formatted as JSON when output is not a terminal or when
+// output is collected (JOURNAL_STREAM environment variable is set or the program runs
+// inside a container). Otherwise they are human-friendly formatted and colorized,
+// unless NO_COLOR environment variable is set.
 //
 // Level can be trace, debug, info, warn, and error.
 //
 //nolint:lll
 type Console struct {
-	Type  string        `default:"${defaultLoggingConsoleType}"  enum:"color,nocolor,json,disable"  help:"Type of console logging."                    json:"type"  placeholder:"TYPE"  yaml:"type"`
-	Level zerolog.Level `default:"${defaultLoggingConsoleLevel}" enum:"trace,debug,info,warn,error" help:"Filter out all log entries below the level." json:"level" placeholder:"LEVEL" yaml:"level"`
+	Type   string        `default:"${defaultLoggingConsoleType}"   enum:"auto,color,nocolor,json,logfmt,disable" help:"Type of console logging."                                                         json:"type"   placeholder:"TYPE"   yaml:"type"`
+	Level  zerolog.Level `default:"${defaultLoggingConsoleLevel}"  enum:"trace,debug,info,warn,error"            help:"Filter out all log entries below the level."                                      json:"level"  placeholder:"LEVEL"  yaml:"level"`
+	Target string        `default:"${defaultLoggingConsoleTarget}" enum:"stdout,stderr,split"                    help:"Where to write log entries: stdout, stderr, or split (warn and above to stderr)." json:"target" placeholder:"TARGET" yaml:"target"`
 
 	// Used primarily for testing.
-	Output io.Writer `json:"-" kong:"-" yaml:"-"`
+	Output      io.Writer `json:"-" kong:"-" yaml:"-"`
+	ErrorOutput io.Writer `json:"-" kong:"-" yaml:"-"`
 }
 
 // UnmarshalYAML implements yaml.BytesUnmarshaler.
 func (c *Console) UnmarshalYAML(b []byte) error {
 	var tmp struct {
-		Type  *string `yaml:"type"`
-		Level *string `yaml:"level"`
+		Type   *string `yaml:"type"`
+		Level  *string `yaml:"level"`
+		Target *string `yaml:"target"`
 	}
 
 	err := yaml.NewDecoder(bytes.NewReader(b), yaml.DisallowUnknownField()).Decode(&tmp)
@@ -107,14 +127,19 @@ func (c *Console) UnmarshalYAML(b []byte) error {
 		c.Type = *tmp.Type
 	}
 
+	if tmp.Target != nil {
+		c.Target = *tmp.Target
+	}
+
 	return nil
 }
 
 // UnmarshalJSON implements json.Unmarshaler interface for Console.
 func (c *Console) UnmarshalJSON(b []byte) error {
 	var tmp struct {
-		Type  *string `json:"type"`
-		Level *string `json:"level"`
+		Type   *string `json:"type"`
+		Level  *string `json:"level"`
+		Target *string `json:"target"`
 	}
 
 	errE := x.UnmarshalWithoutUnknownFields(b, &tmp)
@@ -133,24 +158,60 @@ func (c *Console) UnmarshalJSON(b []byte) error {
 		c.Type = *tmp.Type
 	}
 
+	if tmp.Target != nil {
+		c.Target = *tmp.Target
+	}
+
 	return nil
 }
 
-// File is configuration of logging log entries as JSON by appending them to a file at path.
+// File is configuration of logging log entries by appending them to a file at path.
 //
 // Level can be trace, debug, info, warn, and error.
 //
+// Format can be the following values: json (default), color (human-friendly
+// formatted and colorized), nocolor (just human-friendly formatted), logfmt.
+//
+// Mode is the permission mode used when creating the file (0o600 by default).
+//
+// If MaxSize is set, the file is rotated once it would grow over MaxSize bytes.
+// If Rotate is set (it can be hourly or daily), the file is rotated also when
+// the period changes. Rotated files are kept as numbered backups (path.1 being
+// the most recent one) or, if Rotate is set, as backups with period's timestamp
+// suffix. At most MaxBackups of them are kept (all of them if MaxBackups is 0)
+// and those older than MaxAge are removed (none if MaxAge is 0).
+// If Compress is set, rotated files are compressed with gzip.
+//
+// If Reopen is set, the file is reopened when the process receives SIGHUP.
+// This is useful when the file is rotated externally (e.g., by logrotate).
+//
 //nolint:lll
 type File struct {
-	Path  string        `                                                                        help:"Append log entries to a file (as well)."     json:"path"  placeholder:"PATH"  type:"path" yaml:"path"`
-	Level zerolog.Level `default:"${defaultLoggingFileLevel}" enum:"trace,debug,info,warn,error" help:"Filter out all log entries below the level." json:"level" placeholder:"LEVEL"             yaml:"level"`
+	Path       string        `                                                                        help:"Append log entries to a file (as well)."                    json:"path"       placeholder:"PATH"     type:"path" yaml:"path"`
+	Level      zerolog.Level `default:"${defaultLoggingFileLevel}" enum:"trace,debug,info,warn,error" help:"Filter out all log entries below the level."                json:"level"      placeholder:"LEVEL"                yaml:"level"`
+	Format     string        `                                                                        help:"Format of log entries: json, color, nocolor, or logfmt."    json:"format"     placeholder:"FORMAT"               yaml:"format"`
+	Mode       os.FileMode   `                                                                        help:"Permission mode used when creating the file."               json:"mode"       placeholder:"MODE"                 yaml:"mode"`
+	MaxSize    int64         `                                                                        help:"Rotate the file once it would grow over the size in bytes." json:"maxSize"    placeholder:"BYTES"                yaml:"maxSize"`
+	MaxBackups int           `                                                                        help:"Keep at most this many rotated files."                      json:"maxBackups" placeholder:"NUMBER"               yaml:"maxBackups"`
+	MaxAge     time.Duration `                                                                        help:"Remove rotated files older than the duration."              json:"maxAge"     placeholder:"DURATION"             yaml:"maxAge"`
+	Rotate     string        `default:""                           enum:",daily,hourly"               help:"Rotate the file also hourly or daily."                      json:"rotate"     placeholder:"PERIOD"               yaml:"rotate"`
+	Compress   bool          `                                                                        help:"Compress rotated files with gzip."                          json:"compress"                                      yaml:"compress"`
+	Reopen     bool          `                                                                        help:"Reopen the file on SIGHUP."                                 json:"reopen"                                        yaml:"reopen"`
 }
 
 // UnmarshalYAML implements yaml.BytesUnmarshaler.
 func (f *File) UnmarshalYAML(b []byte) error {
 	var tmp struct {
-		Path  *string `yaml:"path"`
-		Level *string `yaml:"level"`
+		Path       *string        `yaml:"path"`
+		Level      *string        `yaml:"level"`
+		Format     *string        `yaml:"format"`
+		Mode       *fileModeValue `yaml:"mode"`
+		MaxSize    *int64         `yaml:"maxSize"`
+		MaxBackups *int           `yaml:"maxBackups"`
+		MaxAge     *durationValue `yaml:"maxAge"`
+		Rotate     *string        `yaml:"rotate"`
+		Compress   *bool          `yaml:"compress"`
+		Reopen     *bool          `yaml:"reopen"`
 	}
 
 	err := yaml.NewDecoder(bytes.NewReader(b), yaml.DisallowUnknownField()).Decode(&tmp)
@@ -171,14 +232,54 @@ func (f *File) UnmarshalYAML(b []byte) error {
 		f.Path = *tmp.Path
 	}
 
+	if tmp.Format != nil {
+		f.Format = *tmp.Format
+	}
+
+	if tmp.Mode != nil {
+		f.Mode = os.FileMode(*tmp.Mode)
+	}
+
+	if tmp.MaxSize != nil {
+		f.MaxSize = *tmp.MaxSize
+	}
+
+	if tmp.MaxBackups != nil {
+		f.MaxBackups = *tmp.MaxBackups
+	}
+
+	if tmp.MaxAge != nil {
+		f.MaxAge = time.Duration(*tmp.MaxAge)
+	}
+
+	if tmp.Rotate != nil {
+		f.Rotate = *tmp.Rotate
+	}
+
+	if tmp.Compress != nil {
+		f.Compress = *tmp.Compress
+	}
+
+	if tmp.Reopen != nil {
+		f.Reopen = *tmp.Reopen
+	}
+
 	return nil
 }
 
 // UnmarshalJSON implements json.Unmarshaler interface for File.
 func (f *File) UnmarshalJSON(b []byte) error {
 	var tmp struct {
-		Path  *string `json:"path"`
-		Level *string `json:"level"`
+		Path       *string        `json:"path"`
+		Level      *string        `json:"level"`
+		Format     *string        `json:"format"`
+		Mode       *fileModeValue `json:"mode"`
+		MaxSize    *int64         `json:"maxSize"`
+		MaxBackups *int           `json:"maxBackups"`
+		MaxAge     *durationValue `json:"maxAge"`
+		Rotate     *string        `json:"rotate"`
+		Compress   *bool          `json:"compress"`
+		Reopen     *bool          `json:"reopen"`
 	}
 
 	errE := x.UnmarshalWithoutUnknownFields(b, &tmp)
@@ -197,6 +298,444 @@ func (f *File) UnmarshalJSON(b []byte) error {
 		f.Path = *tmp.Path
 	}
 
+	if tmp.Format != nil {
+		f.Format = *tmp.Format
+	}
+
+	if tmp.Mode != nil {
+		f.Mode = os.FileMode(*tmp.Mode)
+	}
+
+	if tmp.MaxSize != nil {
+		f.MaxSize = *tmp.MaxSize
+	}
+
+	if tmp.MaxBackups != nil {
+		f.MaxBackups = *tmp.MaxBackups
+	}
+
+	if tmp.MaxAge != nil {
+		f.MaxAge = time.Duration(*tmp.MaxAge)
+	}
+
+	if tmp.Rotate != nil {
+		f.Rotate = *tmp.Rotate
+	}
+
+	if tmp.Compress != nil {
+		f.Compress = *tmp.Compress
+	}
+
+	if tmp.Reopen != nil {
+		f.Reopen = *tmp.Reopen
+	}
+
+	return nil
+}
+
+// Decode implements kong.MapperValue interface for File.
+//
+// It is used when File is an element of a list of files (and not
+// embedded) and parses a value of the form PATH[,KEY=VALUE...], e.g.,
+// "errors.log,level=error,format=json,mode=0640". Keys are the same as
+// JSON field names. Boolean keys can be provided without a value.
+// Alternatively, the value can be a JSON object.
+//
+// The path is expanded in the same way as Kong expands paths.
+func (f *File) Decode(ctx *kong.DecodeContext) error {
+	var value string
+	err := ctx.Scan.PopValueInto("file", &value)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if strings.HasPrefix(value, "{") {
+		err := f.UnmarshalJSON([]byte(value))
+		if err != nil {
+			return err
+		}
+		f.Path = kong.ExpandPath(f.Path)
+		return nil
+	}
+
+	parts := strings.Split(value, ",")
+	f.Path = kong.ExpandPath(parts[0])
+	for _, part := range parts[1:] {
+		key, v, hasValue := strings.Cut(part, "=")
+		var errE errors.E
+		switch key {
+		case "level":
+			level, err := zerolog.ParseLevel(v)
+			if err != nil {
+				errE = errors.WithStack(err)
+			}
+			f.Level = level
+		case "format":
+			f.Format = v
+		case "mode":
+			f.Mode, errE = parseFileMode(v)
+		case "maxSize":
+			maxSize, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				errE = errors.WithStack(err)
+			}
+			f.MaxSize = maxSize
+		case "maxBackups":
+			maxBackups, err := strconv.Atoi(v)
+			if err != nil {
+				errE = errors.WithStack(err)
+			}
+			f.MaxBackups = maxBackups
+		case "maxAge":
+			maxAge, err := time.ParseDuration(v)
+			if err != nil {
+				errE = errors.WithStack(err)
+			}
+			f.MaxAge = maxAge
+		case "rotate":
+			f.Rotate = v
+		case "compress":
+			f.Compress, errE = parseFlag(v, hasValue)
+		case "reopen":
+			f.Reopen, errE = parseFlag(v, hasValue)
+		default:
+			errE = errors.New("unknown key")
+		}
+		if errE != nil {
+			errors.Details(errE)["key"] = key
+			errors.Details(errE)["value"] = v
+			return errE
+		}
+	}
+
+	return nil
+}
+
+// parseFileMode parses an octal permission mode, e.g., "0640" or "0o640".
+func parseFileMode(value string) (os.FileMode, errors.E) {
+	mode, err := strconv.ParseUint(strings.TrimPrefix(value, "0o"), 8, 32)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return os.FileMode(mode), nil
+}
+
+// fileModeValue is a permission mode which can be unmarshaled from an octal string
+// (e.g., "0640") or from a number (which is how os.FileMode is marshaled).
+type fileModeValue os.FileMode
+
+// UnmarshalYAML implements yaml.BytesUnmarshaler.
+func (m *fileModeValue) UnmarshalYAML(b []byte) error {
+	var value interface{}
+	err := yaml.Unmarshal(b, &value)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	switch v := value.(type) {
+	case string:
+		mode, errE := parseFileMode(v)
+		if errE != nil {
+			return errE
+		}
+		*m = fileModeValue(mode)
+	case uint64:
+		*m = fileModeValue(v) //nolint:gosec
+	case int64:
+		*m = fileModeValue(v) //nolint:gosec
+	case int:
+		*m = fileModeValue(v) //nolint:gosec
+	default:
+		errE := errors.New("invalid file mode")
+		errors.Details(errE)["value"] = value
+		return errE
+	}
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface for fileModeValue.
+func (m *fileModeValue) UnmarshalJSON(b []byte) error {
+	var s string
+	if json.Unmarshal(b, &s) == nil {
+		mode, errE := parseFileMode(s)
+		if errE != nil {
+			return errE
+		}
+		*m = fileModeValue(mode)
+		return nil
+	}
+	var mode uint32
+	err := json.Unmarshal(b, &mode)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	*m = fileModeValue(mode)
+	return nil
+}
+
+// durationValue is a duration which can be unmarshaled from a string (e.g., "1h")
+// or from a number of nanoseconds (which is how time.Duration is marshaled to JSON).
+type durationValue time.Duration
+
+// UnmarshalYAML implements yaml.BytesUnmarshaler.
+func (d *durationValue) UnmarshalYAML(b []byte) error {
+	var value interface{}
+	err := yaml.Unmarshal(b, &value)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	switch v := value.(type) {
+	case string:
+		duration, err := time.ParseDuration(v)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		*d = durationValue(duration)
+	case uint64:
+		*d = durationValue(v) //nolint:gosec
+	case int64:
+		*d = durationValue(v)
+	case int:
+		*d = durationValue(v)
+	default:
+		errE := errors.New("invalid duration")
+		errors.Details(errE)["value"] = value
+		return errE
+	}
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface for durationValue.
+func (d *durationValue) UnmarshalJSON(b []byte) error {
+	var s string
+	if json.Unmarshal(b, &s) == nil {
+		duration, err := time.ParseDuration(s)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		*d = durationValue(duration)
+		return nil
+	}
+	var duration int64
+	err := json.Unmarshal(b, &duration)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	*d = durationValue(duration)
+	return nil
+}
+
+// parseFlag parses a boolean value which is true if not provided.
+func parseFlag(value string, hasValue bool) (bool, errors.E) {
+	if !hasValue {
+		return true, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	return b, nil
+}
+
+// Syslog is configuration of logging to a syslog server.
+//
+// Address is an URL of the syslog server, e.g., udp://localhost:514,
+// tcp://localhost:514, unix:///dev/log, or unixgram:///dev/log.
+// If the port is omitted, 514 is used. Logging to syslog is enabled
+// only if Address is set.
+//
+// Level can be trace, debug, info, warn, and error. Zerolog levels are
+// mapped to syslog severities.
+//
+// Facility can be kern, user, mail, daemon, auth, syslog, lpr, news, uucp,
+// cron, authpriv, ftp, and local0 to local7. If not set, user is used.
+// If AppName is not set, the name of the executable is used.
+//
+// Protocol can be rfc5424 or rfc3164. If not set, rfc5424 is used.
+// Payload can be json or message. With json (the default) the whole JSON log
+// entry is sent as the message. With message only the log entry's message is sent
+// while other fields are rendered as structured data.
+//
+//nolint:lll
+type Syslog struct {
+	Address  string        `                                                                                                                                                                         help:"Send log entries to a syslog server at the address URL." json:"address"  placeholder:"URL"      yaml:"address"`
+	Level    zerolog.Level `default:"${defaultLoggingSyslogLevel}" enum:"trace,debug,info,warn,error"                                                                                                help:"Filter out all log entries below the level."             json:"level"    placeholder:"LEVEL"    yaml:"level"`
+	Facility string        `default:""                             enum:",kern,user,mail,daemon,auth,syslog,lpr,news,uucp,cron,authpriv,ftp,local0,local1,local2,local3,local4,local5,local6,local7" help:"Syslog facility."                                        json:"facility" placeholder:"FACILITY" yaml:"facility"`
+	AppName  string        `                                                                                                                                                                         help:"Application name."                                       json:"appName"  placeholder:"NAME"     yaml:"appName"`
+	Protocol string        `default:""                             enum:",rfc5424,rfc3164"                                                                                                           help:"Syslog protocol: rfc5424 or rfc3164."                    json:"protocol" placeholder:"PROTOCOL" yaml:"protocol"`
+	Payload  string        `default:""                             enum:",json,message"                                                                                                              help:"Payload of syslog messages: json or message."            json:"payload"  placeholder:"PAYLOAD"  yaml:"payload"`
+}
+
+// UnmarshalYAML implements yaml.BytesUnmarshaler.
+func (s *Syslog) UnmarshalYAML(b []byte) error {
+	var tmp struct {
+		Address  *string `yaml:"address"`
+		Level    *string `yaml:"level"`
+		Facility *string `yaml:"facility"`
+		AppName  *string `yaml:"appName"`
+		Protocol *string `yaml:"protocol"`
+		Payload  *string `yaml:"payload"`
+	}
+
+	err := yaml.NewDecoder(bytes.NewReader(b), yaml.DisallowUnknownField()).Decode(&tmp)
+	if errors.Is(err, io.EOF) { //nolint:revive
+		// Nothing.
+	} else if err != nil {
+		return errors.WithStack(err)
+	}
+	if tmp.Level != nil {
+		level, err := zerolog.ParseLevel(*tmp.Level)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		s.Level = level
+	}
+
+	if tmp.Address != nil {
+		s.Address = *tmp.Address
+	}
+
+	if tmp.Facility != nil {
+		s.Facility = *tmp.Facility
+	}
+
+	if tmp.AppName != nil {
+		s.AppName = *tmp.AppName
+	}
+
+	if tmp.Protocol != nil {
+		s.Protocol = *tmp.Protocol
+	}
+
+	if tmp.Payload != nil {
+		s.Payload = *tmp.Payload
+	}
+
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface for Syslog.
+func (s *Syslog) UnmarshalJSON(b []byte) error {
+	var tmp struct {
+		Address  *string `json:"address"`
+		Level    *string `json:"level"`
+		Facility *string `json:"facility"`
+		AppName  *string `json:"appName"`
+		Protocol *string `json:"protocol"`
+		Payload  *string `json:"payload"`
+	}
+
+	errE := x.UnmarshalWithoutUnknownFields(b, &tmp)
+	if errE != nil {
+		return errE
+	}
+	if tmp.Level != nil {
+		level, err := zerolog.ParseLevel(*tmp.Level)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		s.Level = level
+	}
+
+	if tmp.Address != nil {
+		s.Address = *tmp.Address
+	}
+
+	if tmp.Facility != nil {
+		s.Facility = *tmp.Facility
+	}
+
+	if tmp.AppName != nil {
+		s.AppName = *tmp.AppName
+	}
+
+	if tmp.Protocol != nil {
+		s.Protocol = *tmp.Protocol
+	}
+
+	if tmp.Payload != nil {
+		s.Payload = *tmp.Payload
+	}
+
+	return nil
+}
+
+// Journald is configuration of logging to journald.
+//
+// If Enable is set, log entries are sent to journald using its native
+// protocol over a unix datagram socket at Socket path
+// (/run/systemd/journal/socket if not set).
+//
+// Level can be trace, debug, info, warn, and error. Zerolog levels are
+// mapped to journal priorities.
+//
+//nolint:lll
+type Journald struct {
+	Enable bool          `                                                                            help:"Send log entries to journald."               json:"enable"                     yaml:"enable"`
+	Level  zerolog.Level `default:"${defaultLoggingJournaldLevel}" enum:"trace,debug,info,warn,error" help:"Filter out all log entries below the level." json:"level"  placeholder:"LEVEL" yaml:"level"`
+	Socket string        `                                                                            help:"Path to journald's socket."                  json:"socket" placeholder:"PATH"  yaml:"socket"`
+}
+
+// UnmarshalYAML implements yaml.BytesUnmarshaler.
+func (j *Journald) UnmarshalYAML(b []byte) error {
+	var tmp struct {
+		Enable *bool   `yaml:"enable"`
+		Level  *string `yaml:"level"`
+		Socket *string `yaml:"socket"`
+	}
+
+	err := yaml.NewDecoder(bytes.NewReader(b), yaml.DisallowUnknownField()).Decode(&tmp)
+	if errors.Is(err, io.EOF) { //nolint:revive
+		// Nothing.
+	} else if err != nil {
+		return errors.WithStack(err)
+	}
+	if tmp.Level != nil {
+		level, err := zerolog.ParseLevel(*tmp.Level)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		j.Level = level
+	}
+
+	if tmp.Enable != nil {
+		j.Enable = *tmp.Enable
+	}
+
+	if tmp.Socket != nil {
+		j.Socket = *tmp.Socket
+	}
+
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface for Journald.
+func (j *Journald) UnmarshalJSON(b []byte) error {
+	var tmp struct {
+		Enable *bool   `json:"enable"`
+		Level  *string `json:"level"`
+		Socket *string `json:"socket"`
+	}
+
+	errE := x.UnmarshalWithoutUnknownFields(b, &tmp)
+	if errE != nil {
+		return errE
+	}
+	if tmp.Level != nil {
+		level, err := zerolog.ParseLevel(*tmp.Level)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		j.Level = level
+	}
+
+	if tmp.Enable != nil {
+		j.Enable = *tmp.Enable
+	}
+
+	if tmp.Socket != nil {
+		j.Socket = *tmp.Socket
+	}
+
 	return nil
 }
 
@@ -205,15 +744,31 @@ func (f *File) UnmarshalJSON(b []byte) error {
 // Level can be trace, debug, info, warn, and error.
 // Level can be also disabled to disable main logger.
 //
+// If Slog is set, slog's default logger is set to log through the main logger
+// (see SlogHandler).
+//
+// Log entries of Go's standard log package are logged through the main logger
+// at the level parsed from their prefix (e.g., "ERROR:", "[WARN]", or "level=debug"),
+// which is stripped from the message. Log entries without such prefix are logged
+// at StdlogLevel, or without a level if StdlogLevel is not set.
+// If StdlogCaller is set, the caller of the standard log package's function
+// is added to those log entries.
+//
 //nolint:lll
 type Main struct {
-	Level zerolog.Level `default:"${defaultLoggingMainLevel}" enum:"trace,debug,info,warn,error,disabled" env:"LOGGING_MAIN_LEVEL" help:"Log entries at the level or higher." json:"level" placeholder:"LEVEL" short:"l" yaml:"level"`
+	Level        zerolog.Level  `default:"${defaultLoggingMainLevel}" enum:"trace,debug,info,warn,error,disabled" env:"LOGGING_MAIN_LEVEL" help:"Log entries at the level or higher."                                                                                json:"level"        placeholder:"LEVEL" short:"l" yaml:"level"`
+	Slog         bool           `                                                                                                          help:"Set slog's default logger to log through the main logger."                                                          json:"slog"                                       yaml:"slog"`
+	StdlogLevel  *zerolog.Level `                                     enum:"trace,debug,info,warn,error"                                   help:"Log entries of Go's standard log package without a level prefix at the level. By default they do not have a level." json:"stdlogLevel"  placeholder:"LEVEL"           yaml:"stdlogLevel"`
+	StdlogCaller bool           `                                                                                                          help:"Add caller to log entries of Go's standard log package."                                                            json:"stdlogCaller"                               yaml:"stdlogCaller"`
 }
 
 // UnmarshalYAML implements yaml.BytesUnmarshaler.
 func (m *Main) UnmarshalYAML(b []byte) error {
 	var tmp struct {
-		Level string `yaml:"level"`
+		Level        string `yaml:"level"`
+		Slog         bool   `yaml:"slog"`
+		StdlogLevel  string `yaml:"stdlogLevel"`
+		StdlogCaller bool   `yaml:"stdlogCaller"`
 	}
 
 	err := yaml.NewDecoder(bytes.NewReader(b), yaml.DisallowUnknownField()).Decode(&tmp)
@@ -227,7 +782,20 @@ func (m *Main) UnmarshalYAML(b []byte) error {
 		return errors.WithStack(err)
 	}
 
+	// Standard log level is optional.
+	var stdlogLevel *zerolog.Level
+	if tmp.StdlogLevel != "" {
+		l, err := zerolog.ParseLevel(tmp.StdlogLevel)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		stdlogLevel = &l
+	}
+
 	m.Level = level
+	m.Slog = tmp.Slog
+	m.StdlogLevel = stdlogLevel
+	m.StdlogCaller = tmp.StdlogCaller
 
 	return nil
 }
@@ -235,7 +803,10 @@ func (m *Main) UnmarshalYAML(b []byte) error {
 // UnmarshalJSON implements json.Unmarshaler interface for Main.
 func (m *Main) UnmarshalJSON(b []byte) error {
 	var tmp struct {
-		Level string `json:"level"`
+		Level        string `json:"level"`
+		Slog         bool   `json:"slog"`
+		StdlogLevel  string `json:"stdlogLevel"`
+		StdlogCaller bool   `json:"stdlogCaller"`
 	}
 
 	errE := x.UnmarshalWithoutUnknownFields(b, &tmp)
@@ -247,7 +818,20 @@ func (m *Main) UnmarshalJSON(b []byte) error {
 		return errors.WithStack(err)
 	}
 
+	// Standard log level is optional.
+	var stdlogLevel *zerolog.Level
+	if tmp.StdlogLevel != "" {
+		l, err := zerolog.ParseLevel(tmp.StdlogLevel)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		stdlogLevel = &l
+	}
+
 	m.Level = level
+	m.Slog = tmp.Slog
+	m.StdlogLevel = stdlogLevel
+	m.StdlogCaller = tmp.StdlogCaller
 
 	return nil
 }
@@ -261,19 +845,64 @@ func (m *Main) UnmarshalJSON(b []byte) error {
 // entry at the TriggerLevel or higher. To disable this behavior, set Level and TriggerLevel
 // to the same level.
 //
+// The buffer keeps at most MaxEntries log entries and MaxBytes bytes (no limit if 0,
+// they cannot be negative). When a limit is reached, the oldest buffered log entries
+// are dropped and, once triggered, a log entry with the number of dropped log entries
+// is logged first.
+//
+// If Summary is set, a log entry at SummaryLevel with the number of discarded
+// log entries per level is logged (with the context logger's fields) when the
+// context logger is closed without being triggered.
+//
+// If TriggerOnDeadline is set, buffered log entries are flushed (triggered) when
+// the context to which the logger has been added ends because its deadline has been
+// exceeded. TriggerOnDone can be set to a function which is called with the cause
+// of the context's end and triggers if it returns true.
+//
+// Triggers can be set to functions which are called for every log entry
+// (until triggered) and trigger if any of them returns true. See TriggerOnField,
+// TriggerOnFieldValue, and TriggerOnCount.
+//
 //nolint:lll
 type Context struct {
-	Level            zerolog.Level `default:"${defaultLoggingContextLevel}"            enum:"trace,debug,info,warn,error,disabled" help:"Log entries at the level or higher."                        json:"level"                               placeholder:"LEVEL" yaml:"level"`
-	ConditionalLevel zerolog.Level `default:"${defaultLoggingContextConditionalLevel}" enum:"trace,debug,info,warn,error"          help:"Buffer log entries at the level and below until triggered." json:"conditionalLevel" name:"conditional" placeholder:"LEVEL" yaml:"conditionalLevel"`
-	TriggerLevel     zerolog.Level `default:"${defaultLoggingContextTriggerLevel}"     enum:"trace,debug,info,warn,error"          help:"A log entry at the level or higher triggers."               json:"triggerLevel"     name:"trigger"     placeholder:"LEVEL" yaml:"triggerLevel"`
+	Level             zerolog.Level          `default:"${defaultLoggingContextLevel}"            enum:"trace,debug,info,warn,error,disabled" help:"Log entries at the level or higher."                                          json:"level"                                placeholder:"LEVEL"  yaml:"level"`
+	ConditionalLevel  zerolog.Level          `default:"${defaultLoggingContextConditionalLevel}" enum:"trace,debug,info,warn,error"          help:"Buffer log entries at the level and below until triggered."                   json:"conditionalLevel"  name:"conditional" placeholder:"LEVEL"  yaml:"conditionalLevel"`
+	TriggerLevel      zerolog.Level          `default:"${defaultLoggingContextTriggerLevel}"     enum:"trace,debug,info,warn,error"          help:"A log entry at the level or higher triggers."                                 json:"triggerLevel"      name:"trigger"     placeholder:"LEVEL"  yaml:"triggerLevel"`
+	MaxEntries        int                    `                                                                                               help:"Buffer at most this many log entries, dropping the oldest ones."              json:"maxEntries"                           placeholder:"NUMBER" yaml:"maxEntries"`
+	MaxBytes          int                    `                                                                                               help:"Buffer at most this many bytes of log entries, dropping the oldest ones."     json:"maxBytes"                             placeholder:"BYTES"  yaml:"maxBytes"`
+	Summary           bool                   `                                                                                               help:"Log the number of discarded log entries when closed without being triggered." json:"summary"                                                   yaml:"summary"`
+	SummaryLevel      zerolog.Level          `default:"${defaultLoggingContextSummaryLevel}"     enum:"trace,debug,info,warn,error"          help:"Log the summary at the level."                                                json:"summaryLevel"                         placeholder:"LEVEL"  yaml:"summaryLevel"`
+	TriggerOnDeadline bool                   `                                                                                               help:"Trigger when the context's deadline is exceeded."                             json:"triggerOnDeadline"                                         yaml:"triggerOnDeadline"`
+	TriggerOnDone     func(cause error) bool `json:"-" kong:"-" yaml:"-"`
+	Triggers          []TriggerFunc          `json:"-" kong:"-" yaml:"-"`
+}
+
+// validateContextLimits returns an error if context logger's buffer limits are negative.
+func validateContextLimits(maxEntries, maxBytes int) errors.E {
+	if maxEntries < 0 {
+		errE := errors.New("invalid context logger max entries")
+		errors.Details(errE)["value"] = maxEntries
+		return errE
+	}
+	if maxBytes < 0 {
+		errE := errors.New("invalid context logger max bytes")
+		errors.Details(errE)["value"] = maxBytes
+		return errE
+	}
+	return nil
 }
 
 // UnmarshalYAML implements yaml.BytesUnmarshaler.
 func (c *Context) UnmarshalYAML(b []byte) error {
 	var tmp struct {
-		Level            string `yaml:"level"`
-		ConditionalLevel string `yaml:"conditionalLevel"`
-		TriggerLevel     string `yaml:"triggerLevel"`
+		Level             string `yaml:"level"`
+		ConditionalLevel  string `yaml:"conditionalLevel"`
+		TriggerLevel      string `yaml:"triggerLevel"`
+		MaxEntries        int    `yaml:"maxEntries"`
+		MaxBytes          int    `yaml:"maxBytes"`
+		Summary           bool   `yaml:"summary"`
+		SummaryLevel      string `yaml:"summaryLevel"`
+		TriggerOnDeadline bool   `yaml:"triggerOnDeadline"`
 	}
 
 	err := yaml.NewDecoder(bytes.NewReader(b), yaml.DisallowUnknownField()).Decode(&tmp)
@@ -294,10 +923,27 @@ func (c *Context) UnmarshalYAML(b []byte) error {
 	if err != nil {
 		return errors.WithStack(err)
 	}
+	// Summary level is optional.
+	if tmp.SummaryLevel == "" {
+		tmp.SummaryLevel = DefaultContextSummaryLevel
+	}
+	summaryLevel, err := zerolog.ParseLevel(tmp.SummaryLevel)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	errE := validateContextLimits(tmp.MaxEntries, tmp.MaxBytes)
+	if errE != nil {
+		return errE
+	}
 
 	c.Level = level
 	c.ConditionalLevel = conditionalLevel
 	c.TriggerLevel = triggerLevel
+	c.MaxEntries = tmp.MaxEntries
+	c.MaxBytes = tmp.MaxBytes
+	c.Summary = tmp.Summary
+	c.SummaryLevel = summaryLevel
+	c.TriggerOnDeadline = tmp.TriggerOnDeadline
 
 	return nil
 }
@@ -305,9 +951,14 @@ func (c *Context) UnmarshalYAML(b []byte) error {
 // UnmarshalJSON implements json.Unmarshaler interface for Context.
 func (c *Context) UnmarshalJSON(b []byte) error {
 	var tmp struct {
-		Level            string `json:"level"`
-		ConditionalLevel string `json:"conditionalLevel"`
-		TriggerLevel     string `json:"triggerLevel"`
+		Level             string `json:"level"`
+		ConditionalLevel  string `json:"conditionalLevel"`
+		TriggerLevel      string `json:"triggerLevel"`
+		MaxEntries        int    `json:"maxEntries"`
+		MaxBytes          int    `json:"maxBytes"`
+		Summary           bool   `json:"summary"`
+		SummaryLevel      string `json:"summaryLevel"`
+		TriggerOnDeadline bool   `json:"triggerOnDeadline"`
 	}
 
 	errE := x.UnmarshalWithoutUnknownFields(b, &tmp)
@@ -326,24 +977,52 @@ func (c *Context) UnmarshalJSON(b []byte) error {
 	if err != nil {
 		return errors.WithStack(err)
 	}
+	// Summary level is optional.
+	if tmp.SummaryLevel == "" {
+		tmp.SummaryLevel = DefaultContextSummaryLevel
+	}
+	summaryLevel, err := zerolog.ParseLevel(tmp.SummaryLevel)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	errE = validateContextLimits(tmp.MaxEntries, tmp.MaxBytes)
+	if errE != nil {
+		return errE
+	}
 
 	c.Level = level
 	c.ConditionalLevel = conditionalLevel
 	c.TriggerLevel = triggerLevel
+	c.MaxEntries = tmp.MaxEntries
+	c.MaxBytes = tmp.MaxBytes
+	c.Summary = tmp.Summary
+	c.SummaryLevel = summaryLevel
+	c.TriggerOnDeadline = tmp.TriggerOnDeadline
 
 	return nil
 }
 
-// Logging is configuration for console and file logging.
+// Logging is configuration for console, file, syslog, and journald logging.
+//
+// Besides File, additional files can be configured in Files,
+// each with its own level, format, and other options.
 type Logging struct {
-	Console Console `embed:"" json:"console" prefix:"console." yaml:"console"`
-	File    File    `embed:"" json:"file"    prefix:"file."    yaml:"file"`
-	Main    Main    `embed:"" json:"main"    prefix:"main."    yaml:"main"`
-	Context Context `embed:"" json:"context" prefix:"context." yaml:"context"`
+	Console  Console  `embed:""                                                                   json:"console"                                    prefix:"console."             yaml:"console"`
+	File     File     `embed:""                                                                   json:"file"                                       prefix:"file."                yaml:"file"`
+	Files    []File   `         help:"Append log entries to an additional file. Can be repeated." json:"files"    placeholder:"PATH[,KEY=VALUE...]"                    sep:"none" yaml:"files"`
+	Syslog   Syslog   `embed:""                                                                   json:"syslog"                                     prefix:"syslog."              yaml:"syslog"`
+	Journald Journald `embed:""                                                                   json:"journald"                                   prefix:"journald."            yaml:"journald"`
+	Main     Main     `embed:""                                                                   json:"main"                                       prefix:"main."                yaml:"main"`
+	Context  Context  `embed:""                                                                   json:"context"                                    prefix:"context."             yaml:"context"`
 }
 
 // WithContextFunc adds a logger to a context. It returns the new context, a function to close the
 // logger (discarding any buffered entries), and a function to flush (trigger) any buffered entries.
+//
+// If the context already has a logger added by the same WithContextFunc, the new logger is derived
+// from the existing one (keeping its fields) and shares its buffer, so triggering either flushes
+// all buffered entries in order. Closing such nested logger does not discard buffered entries,
+// which are discarded only when the outermost logger is closed.
 type WithContextFunc = func(context.Context) (context.Context, func(), func())
 
 // LoggingConfig struct can be provided embedded inside the config argument to
@@ -505,6 +1184,82 @@ func formatExtra(noColor bool) func(map[string]interface{}, *bytes.Buffer) error
 	}
 }
 
+// containerMarkers are files which exist only inside containers.
+//
+//nolint:gochecknoglobals
+var containerMarkers = []string{
+	// Docker.
+	"/.dockerenv",
+	// Podman.
+	"/run/.containerenv",
+}
+
+// inContainer returns true if the program seems to be running inside a container.
+func inContainer() bool {
+	if os.Getenv("container") != "" || os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return true
+	}
+	for _, marker := range containerMarkers {
+		_, err := os.Stat(marker)
+		if err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// detectConsoleType returns the console type to use for output when
+// console type is auto.
+func detectConsoleType(output io.Writer) string {
+	if os.Getenv("JOURNAL_STREAM") != "" || inContainer() {
+		return "json"
+	}
+	f, ok := output.(interface{ Fd() uintptr })
+	if !ok || !(isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())) {
+		return "json"
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return "nocolor"
+	}
+	return "color"
+}
+
+// newConsoleLevelWriter returns a writer for console logging of the type to output.
+//
+// If the type is auto, the type is detected for output.
+func newConsoleLevelWriter(consoleType string, output io.Writer) zerolog.LevelWriter {
+	if consoleType == "auto" {
+		consoleType = detectConsoleType(output)
+	}
+	switch consoleType {
+	case "json":
+		return zerolog.LevelWriterAdapter{Writer: output}
+	case "logfmt":
+		return zerolog.LevelWriterAdapter{Writer: &logfmtWriter{Out: output}}
+	}
+	return zerolog.LevelWriterAdapter{Writer: newConsoleWriter(consoleType == "nocolor", output)}
+}
+
+// splitLevelWriter writes log entries at warn level and above to High
+// and all other log entries (including those without a level) to Low.
+type splitLevelWriter struct {
+	Low  zerolog.LevelWriter
+	High zerolog.LevelWriter
+}
+
+// Write implements io.Writer interface for splitLevelWriter.
+func (w *splitLevelWriter) Write(p []byte) (int, error) {
+	return w.Low.Write(p) //nolint:wrapcheck
+}
+
+// WriteLevel implements zerolog.LevelWriter interface for splitLevelWriter.
+func (w *splitLevelWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	if level >= zerolog.WarnLevel && level < zerolog.NoLevel {
+		return w.High.WriteLevel(level, p) //nolint:wrapcheck
+	}
+	return w.Low.WriteLevel(level, p) //nolint:wrapcheck
+}
+
 // newConsoleWriter creates and initializes a new ConsoleWriter with 24-hour time
 // format and formatting of errors which have been marshaled into JSON object
 // using gitlab.com/tozd/go/errors's Formatter.
@@ -538,36 +1293,52 @@ func ErrorMarshalFunc(e error) interface{} {
 // and returns the logger in its Logger field and sets its WithContext field.
 // LoggingConfig can be initially populated with configuration using [Kong].
 //
-// Returned file handle belongs to the file to which log entries are appended (if file
-// logging is enabled in configuration). Closing it is caller's responsibility.
+// Returned closer flushes and closes all sinks (e.g., files to which log entries are
+// appended, if file logging is enabled in configuration) and context loggers.
+// Closing it is caller's responsibility.
 //
 // For details on what all is configured and initialized see package's README.
 //
 // [Kong]: https://github.com/alecthomas/kong
-func New[LoggingConfigT hasLoggingConfig](config LoggingConfigT) (*os.File, errors.E) {
+func New[LoggingConfigT hasLoggingConfig](config LoggingConfigT) (*Closer, errors.E) {
 	loggingConfig := config.GetLoggingConfig()
 
+	errE := validateContextLimits(loggingConfig.Logging.Context.MaxEntries, loggingConfig.Logging.Context.MaxBytes)
+	if errE != nil {
+		return nil, errE
+	}
+
 	minOutputLevel := zerolog.Disabled
 	writers := []io.Writer{}
 	output := loggingConfig.Logging.Console.Output
 	if output == nil {
 		output = os.Stdout
 	}
-	var file *os.File
+	errorOutput := loggingConfig.Logging.Console.ErrorOutput
+	if errorOutput == nil {
+		errorOutput = os.Stderr
+	}
+	closer := newCloser()
 	switch loggingConfig.Logging.Console.Type {
-	case "color", "nocolor":
-		w := newConsoleWriter(loggingConfig.Logging.Console.Type == "nocolor", output)
-		writers = append(writers, &zerolog.FilteredLevelWriter{
-			Writer: zerolog.LevelWriterAdapter{Writer: w},
-			Level:  loggingConfig.Logging.Console.Level,
-		})
-		if loggingConfig.Logging.Console.Level < minOutputLevel {
-			minOutputLevel = loggingConfig.Logging.Console.Level
+	case "auto", "color", "nocolor", "json", "logfmt":
+		var w zerolog.LevelWriter
+		switch loggingConfig.Logging.Console.Target {
+		case "", "stdout":
+			w = newConsoleLevelWriter(loggingConfig.Logging.Console.Type, output)
+		case "stderr":
+			w = newConsoleLevelWriter(loggingConfig.Logging.Console.Type, errorOutput)
+		case "split":
+			w = &splitLevelWriter{
+				Low:  newConsoleLevelWriter(loggingConfig.Logging.Console.Type, output),
+				High: newConsoleLevelWriter(loggingConfig.Logging.Console.Type, errorOutput),
+			}
+		default:
+			errE := errors.New("invalid console logging target")
+			errors.Details(errE)["value"] = loggingConfig.Logging.Console.Target
+			return nil, errE
 		}
-	case "json":
-		w := output
 		writers = append(writers, &zerolog.FilteredLevelWriter{
-			Writer: zerolog.LevelWriterAdapter{Writer: w},
+			Writer: w,
 			Level:  loggingConfig.Logging.Console.Level,
 		})
 		if loggingConfig.Logging.Console.Level < minOutputLevel {
@@ -580,18 +1351,66 @@ func New[LoggingConfigT hasLoggingConfig](config LoggingConfigT) (*os.File, erro
 		errors.Details(errE)["value"] = loggingConfig.Logging.Console.Type
 		return nil, errE
 	}
+	files := []File{}
 	if loggingConfig.Logging.File.Path != "" {
-		w, err := os.OpenFile(loggingConfig.Logging.File.Path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, fileMode)
-		if err != nil {
-			return nil, errors.WithMessage(err, "cannot open logging file")
+		files = append(files, loggingConfig.Logging.File)
+	}
+	files = append(files, loggingConfig.Logging.Files...)
+	for _, f := range files {
+		switch f.Format {
+		case "", "json", "color", "nocolor", "logfmt":
+		default:
+			errE := errors.New("invalid file logging format")
+			errors.Details(errE)["value"] = f.Format
+			errors.Details(errE)["path"] = f.Path
+			return nil, errors.Join(errE, closer.Close())
+		}
+		fw, errE := NewFileWriter(f)
+		if errE != nil {
+			return nil, errors.Join(errE, closer.Close())
+		}
+		closer.add(fw)
+		var w io.Writer = fw
+		switch f.Format {
+		case "color", "nocolor":
+			w = newConsoleWriter(f.Format == "nocolor", fw)
+		case "logfmt":
+			w = &logfmtWriter{Out: fw}
 		}
-		file = w
 		writers = append(writers, &zerolog.FilteredLevelWriter{
 			Writer: zerolog.LevelWriterAdapter{Writer: w},
-			Level:  loggingConfig.Logging.File.Level,
+			Level:  f.Level,
+		})
+		if f.Level < minOutputLevel {
+			minOutputLevel = f.Level
+		}
+	}
+	if loggingConfig.Logging.Syslog.Address != "" {
+		sw, errE := NewSyslogWriter(loggingConfig.Logging.Syslog)
+		if errE != nil {
+			return nil, errors.Join(errE, closer.Close())
+		}
+		closer.add(sw)
+		writers = append(writers, &zerolog.FilteredLevelWriter{
+			Writer: sw,
+			Level:  loggingConfig.Logging.Syslog.Level,
 		})
-		if loggingConfig.Logging.File.Level < minOutputLevel {
-			minOutputLevel = loggingConfig.Logging.File.Level
+		if loggingConfig.Logging.Syslog.Level < minOutputLevel {
+			minOutputLevel = loggingConfig.Logging.Syslog.Level
+		}
+	}
+	if loggingConfig.Logging.Journald.Enable {
+		jw, errE := NewJournaldWriter(loggingConfig.Logging.Journald)
+		if errE != nil {
+			return nil, errors.Join(errE, closer.Close())
+		}
+		closer.add(jw)
+		writers = append(writers, &zerolog.FilteredLevelWriter{
+			Writer: jw,
+			Level:  loggingConfig.Logging.Journald.Level,
+		})
+		if loggingConfig.Logging.Journald.Level < minOutputLevel {
+			minOutputLevel = loggingConfig.Logging.Journald.Level
 		}
 	}
 
@@ -623,25 +1442,82 @@ func New[LoggingConfigT hasLoggingConfig](config LoggingConfigT) (*os.File, erro
 
 	log.Logger = mainLogger
 	loggingConfig.Logger = mainLogger
+	if loggingConfig.Logging.Main.Slog {
+		// slog.SetDefault also redirects Go's standard log package, so we have to call it before
+		// we configure the standard log package below to log through the main logger directly.
+		slog.SetDefault(slog.New(NewSlogHandler(mainLogger)))
+	}
+	stdlogLevel := zerolog.NoLevel
+	if loggingConfig.Logging.Main.StdlogLevel != nil {
+		stdlogLevel = *loggingConfig.Logging.Main.StdlogLevel
+	}
 	stdlog.SetFlags(0)
-	stdlog.SetOutput(mainLogger)
+	stdlog.SetOutput(&stdlogWriter{
+		Logger: mainLogger,
+		Level:  stdlogLevel,
+		Caller: loggingConfig.Logging.Main.StdlogCaller,
+	})
 
 	ctxLoggerLevel := max(minOutputLevel, loggingConfig.Logging.Context.Level)
 	if len(writers) > 0 && ctxLoggerLevel < zerolog.Disabled {
+		triggerOnDone := contextTriggerOnDone(loggingConfig.Logging.Context)
+		key := contextWriterKey{closer: closer}
 		loggingConfig.WithContext = func(ctx context.Context) (context.Context, func(), func()) {
-			w := &zerolog.TriggerLevelWriter{
-				Writer:           writer,
-				ConditionalLevel: loggingConfig.Logging.Context.ConditionalLevel,
-				TriggerLevel:     loggingConfig.Logging.Context.TriggerLevel,
+			var ctxLogger zerolog.Logger
+			w, nested := ctx.Value(key).(*contextWriter)
+			if nested {
+				// The context already has a context logger, so we share its writer (and its buffer)
+				// and derive the logger from the context's logger so that added fields are kept.
+				ctxLogger = zerolog.Ctx(ctx).With().Logger()
+			} else {
+				w = &contextWriter{ //nolint:exhaustruct
+					Writer:           writer,
+					ConditionalLevel: loggingConfig.Logging.Context.ConditionalLevel,
+					TriggerLevel:     loggingConfig.Logging.Context.TriggerLevel,
+					MaxEntries:       loggingConfig.Logging.Context.MaxEntries,
+					MaxBytes:         loggingConfig.Logging.Context.MaxBytes,
+					SummaryLevel:     zerolog.Disabled,
+					Triggers:         loggingConfig.Logging.Context.Triggers,
+				}
+				if loggingConfig.Logging.Context.Summary {
+					w.SummaryLevel = loggingConfig.Logging.Context.SummaryLevel
+				}
+				closer.addContext(w)
+				ctxLogger = zerolog.New(w).Level(ctxLoggerLevel).With().Timestamp().Logger()
+				ctx = context.WithValue(ctx, key, w)
+				ctx = context.WithValue(ctx, contextLoggerWriterKey{}, w)
+			}
+			var stop func() bool
+			if triggerOnDone != nil {
+				stop = context.AfterFunc(ctx, func() {
+					if triggerOnDone(context.Cause(ctx)) {
+						_ = w.Trigger()
+					}
+				})
 			}
-			ctxLogger := zerolog.New(w).Level(ctxLoggerLevel).With().Timestamp().Logger()
 			closeCtx := func() {
+				if stop != nil {
+					stop()
+					// The context might have ended just before closing, before the function
+					// registered with AfterFunc had a chance to trigger, so we check here as well.
+					if ctx.Err() != nil && triggerOnDone(context.Cause(ctx)) {
+						_ = w.Trigger()
+					}
+				}
+				if nested {
+					// The writer is closed when the outermost context logger is closed.
+					return
+				}
+				closer.removeContext(w)
 				_ = w.Close()
 			}
 			trigger := func() {
 				_ = w.Trigger()
 			}
-			return ctxLogger.WithContext(ctx), closeCtx, trigger
+			if nested {
+				return ctxLogger.WithContext(ctx), closeCtx, trigger
+			}
+			return withContextLogger(ctx, ctxLogger), closeCtx, trigger
 		}
 	} else {
 		loggingConfig.WithContext = func(ctx context.Context) (context.Context, func(), func()) {
@@ -652,7 +1528,30 @@ func New[LoggingConfigT hasLoggingConfig](config LoggingConfigT) (*os.File, erro
 		}
 	}
 
-	return file, nil
+	return closer, nil
+}
+
+// contextWriterKey is the context key under which the context logger's writer is stored.
+//
+// It includes the closer so that context loggers from different New calls are not shared.
+type contextWriterKey struct {
+	closer *Closer
+}
+
+// contextTriggerOnDone returns a function which returns true if the context logger
+// should be triggered when its context ends with the cause, or nil if it should not
+// be triggered at all.
+func contextTriggerOnDone(config Context) func(error) bool {
+	if !config.TriggerOnDeadline {
+		return config.TriggerOnDone
+	}
+	triggerOnDone := config.TriggerOnDone
+	return func(cause error) bool {
+		if errors.Is(cause, context.DeadlineExceeded) {
+			return true
+		}
+		return triggerOnDone != nil && triggerOnDone(cause)
+	}
 }
 
 // We initialize kongLevelTypeMapper here so that whole definition does not end
@@ -728,44 +1627,3 @@ func PrettyLog(noColor bool, input io.Reader, output io.Writer) errors.E {
 
 	return nil
 }
-
-// NewHandler injects log into requests context.
-//
-// Log entries buffered by the context logger are flushed (triggered) if the request panics or
-// responds with a server error (status code 500 or higher). For other responses the buffered
-// entries are discarded. This makes the detailed debug logging of a request available exactly
-// when something went wrong with the request, even when it does not panic and does not itself
-// log through the context logger at the triggering level.
-func NewHandler(withContext func(context.Context) (context.Context, func(), func())) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-			ctx, closeCtx, trigger := withContext(req.Context())
-			if closeCtx != nil {
-				defer closeCtx()
-			}
-			panicking := true
-			code := http.StatusOK
-			if trigger != nil {
-				defer func() {
-					if panicking || code >= http.StatusInternalServerError {
-						trigger()
-					}
-				}()
-				// We capture the response status code so that we can flush buffered log entries on a server
-				// error even when the request returns normally. If WriteHeader is never called, the response
-				// status code is 200, which is the default we initialize code with.
-				w = httpsnoop.Wrap(w, httpsnoop.Hooks{ //nolint:exhaustruct
-					WriteHeader: func(next httpsnoop.WriteHeaderFunc) httpsnoop.WriteHeaderFunc {
-						return func(c int) {
-							code = c
-							next(c)
-						}
-					},
-				})
-			}
-			req = req.WithContext(ctx)
-			next.ServeHTTP(w, req)
-			panicking = false
-		})
-	}
-}
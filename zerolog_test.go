@@ -17,10 +17,12 @@ import (
 	"runtime"
 	"sort"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/alecthomas/kong"
+	"github.com/goccy/go-yaml"
 	"github.com/rs/zerolog"
 	globallog "github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
@@ -554,21 +556,33 @@ func TestZerolog(t *testing.T) {
 				WithContext: nil,
 				Logging: z.Logging{
 					Console: z.Console{
-						Type:   tt.ConsoleType,
-						Level:  tt.ConsoleLevel,
-						Output: w,
+						Type:        tt.ConsoleType,
+						Level:       tt.ConsoleLevel,
+						Target:      "stdout",
+						Output:      w,
+						ErrorOutput: nil,
 					},
 					File: z.File{
 						Level: tt.FileLevel,
 						Path:  p,
 					},
 					Main: z.Main{
-						Level: zerolog.TraceLevel,
+						Level:        zerolog.TraceLevel,
+						Slog:         false,
+						StdlogLevel:  nil,
+						StdlogCaller: false,
 					},
 					Context: z.Context{
-						Level:            zerolog.TraceLevel,
-						ConditionalLevel: zerolog.TraceLevel,
-						TriggerLevel:     zerolog.TraceLevel,
+						Level:             zerolog.TraceLevel,
+						ConditionalLevel:  zerolog.TraceLevel,
+						TriggerLevel:      zerolog.TraceLevel,
+						MaxEntries:        0,
+						MaxBytes:          0,
+						Summary:           false,
+						SummaryLevel:      zerolog.InfoLevel,
+						TriggerOnDeadline: false,
+						TriggerOnDone:     nil,
+						Triggers:          nil,
 					},
 				},
 			}
@@ -688,21 +702,33 @@ func TestWithContext(t *testing.T) {
 				WithContext: nil,
 				Logging: z.Logging{
 					Console: z.Console{
-						Type:   "nocolor",
-						Level:  tt.ConsoleLevel,
-						Output: buffer,
+						Type:        "nocolor",
+						Level:       tt.ConsoleLevel,
+						Target:      "stdout",
+						Output:      buffer,
+						ErrorOutput: nil,
 					},
 					File: z.File{
 						Level: zerolog.Disabled,
 						Path:  "",
 					},
 					Main: z.Main{
-						Level: zerolog.Disabled,
+						Level:        zerolog.Disabled,
+						Slog:         false,
+						StdlogLevel:  nil,
+						StdlogCaller: false,
 					},
 					Context: z.Context{
-						Level:            tt.ContextLevel,
-						ConditionalLevel: tt.ConditionalLevel,
-						TriggerLevel:     tt.TriggerLevel,
+						Level:             tt.ContextLevel,
+						ConditionalLevel:  tt.ConditionalLevel,
+						TriggerLevel:      tt.TriggerLevel,
+						MaxEntries:        0,
+						MaxBytes:          0,
+						Summary:           false,
+						SummaryLevel:      zerolog.InfoLevel,
+						TriggerOnDeadline: false,
+						TriggerOnDone:     nil,
+						Triggers:          nil,
 					},
 				},
 			}
@@ -737,6 +763,560 @@ func TestWithContext(t *testing.T) {
 	}
 }
 
+func TestWithContextBufferLimits(t *testing.T) {
+	for _, tt := range []struct {
+		Name       string
+		MaxEntries int
+		MaxBytes   int
+		Log        func(logger *zerolog.Logger)
+		Expected   string
+	}{
+		{
+			Name:       "entries",
+			MaxEntries: 2,
+			Log: func(logger *zerolog.Logger) {
+				logger.Trace().Msg("a")
+				logger.Debug().Msg("b")
+				logger.Debug().Msg("c")
+				logger.Debug().Msg("d")
+			},
+			Expected: `^\d{2}:\d{2} DBG buffered log entries dropped dropped=2\n\d{2}:\d{2} DBG c\n\d{2}:\d{2} DBG d\n\d{2}:\d{2} ERR trigger\n$`,
+		},
+		{
+			Name:     "bytes",
+			MaxBytes: 1000,
+			Log: func(logger *zerolog.Logger) {
+				logger.Debug().Msg(strings.Repeat("x", 1000))
+				logger.Trace().Msg("a")
+			},
+			Expected: `^\d{2}:\d{2} DBG buffered log entries dropped dropped=1\n\d{2}:\d{2} TRC a\n\d{2}:\d{2} ERR trigger\n$`,
+		},
+		{
+			Name:       "none",
+			MaxEntries: 2,
+			Log: func(logger *zerolog.Logger) {
+				logger.Debug().Msg("a")
+			},
+			Expected: `^\d{2}:\d{2} DBG a\n\d{2}:\d{2} ERR trigger\n$`,
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			config := z.LoggingConfig{ //nolint:exhaustruct
+				Logging: z.Logging{ //nolint:exhaustruct
+					Console: z.Console{
+						Type:        "nocolor",
+						Level:       zerolog.TraceLevel,
+						Target:      "stdout",
+						Output:      &buffer,
+						ErrorOutput: nil,
+					},
+					Main: z.Main{
+						Level:        zerolog.Disabled,
+						Slog:         false,
+						StdlogLevel:  nil,
+						StdlogCaller: false,
+					},
+					Context: z.Context{
+						Level:             zerolog.TraceLevel,
+						ConditionalLevel:  zerolog.DebugLevel,
+						TriggerLevel:      zerolog.ErrorLevel,
+						MaxEntries:        tt.MaxEntries,
+						MaxBytes:          tt.MaxBytes,
+						Summary:           false,
+						SummaryLevel:      zerolog.InfoLevel,
+						TriggerOnDeadline: false,
+						TriggerOnDone:     nil,
+						Triggers:          nil,
+					},
+				},
+			}
+			closer, errE := z.New(&config)
+			require.NoError(t, errE, "% -+#.1v", errE)
+			t.Cleanup(func() {
+				_ = closer.Close()
+			})
+
+			ctx, closeCtx, _ := config.WithContext(context.Background())
+			t.Cleanup(closeCtx)
+
+			tt.Log(zerolog.Ctx(ctx))
+			assert.Empty(t, buffer.String())
+			zerolog.Ctx(ctx).Error().Msg("trigger")
+			assert.Regexp(t, tt.Expected, buffer.String())
+		})
+	}
+}
+
+func TestContextInvalidBufferLimits(t *testing.T) {
+	for _, tt := range []struct {
+		MaxEntries int
+		MaxBytes   int
+		Error      string
+	}{
+		{-1, 0, "invalid context logger max entries"},
+		{0, -1, "invalid context logger max bytes"},
+	} {
+		t.Run(tt.Error, func(t *testing.T) {
+			config := z.LoggingConfig{ //nolint:exhaustruct
+				Logging: z.Logging{ //nolint:exhaustruct
+					Console: z.Console{ //nolint:exhaustruct
+						Type: "disable",
+					},
+					Context: z.Context{ //nolint:exhaustruct
+						MaxEntries: tt.MaxEntries,
+						MaxBytes:   tt.MaxBytes,
+					},
+				},
+			}
+			_, errE := z.New(&config)
+			assert.EqualError(t, errE, tt.Error)
+
+			var fromJSON z.Context
+			err := json.Unmarshal([]byte(fmt.Sprintf(`{"level":"debug","conditionalLevel":"debug","triggerLevel":"error","maxEntries":%d,"maxBytes":%d}`, tt.MaxEntries, tt.MaxBytes)), &fromJSON)
+			assert.ErrorContains(t, err, tt.Error)
+
+			var fromYAML z.Context
+			err = yaml.Unmarshal([]byte(fmt.Sprintf("level: debug\nconditionalLevel: debug\ntriggerLevel: error\nmaxEntries: %d\nmaxBytes: %d\n", tt.MaxEntries, tt.MaxBytes)), &fromYAML)
+			assert.ErrorContains(t, err, tt.Error)
+		})
+	}
+}
+
+func TestWithContextSummary(t *testing.T) {
+	for _, tt := range []struct {
+		Name     string
+		Summary  bool
+		Log      func(logger *zerolog.Logger)
+		Expected string
+	}{
+		{
+			Name:    "summary",
+			Summary: true,
+			Log: func(logger *zerolog.Logger) {
+				logger.Debug().Msg("a")
+				logger.Trace().Msg("b")
+				logger.Debug().Msg("c")
+			},
+			Expected: `^\d{2}:\d{2} WRN buffered log entries discarded discarded={"debug":2,"trace":1}\n$`,
+		},
+		{
+			Name:    "triggered",
+			Summary: true,
+			Log: func(logger *zerolog.Logger) {
+				logger.Debug().Msg("a")
+				logger.Error().Msg("trigger")
+			},
+			Expected: `^\d{2}:\d{2} DBG a\n\d{2}:\d{2} ERR trigger\n$`,
+		},
+		{
+			Name:    "empty",
+			Summary: true,
+			Log: func(logger *zerolog.Logger) {
+				logger.Info().Msg("a")
+			},
+			Expected: `^\d{2}:\d{2} INF a\n$`,
+		},
+		{
+			Name:    "disabled",
+			Summary: false,
+			Log: func(logger *zerolog.Logger) {
+				logger.Debug().Msg("a")
+			},
+			Expected: `^$`,
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			config := z.LoggingConfig{ //nolint:exhaustruct
+				Logging: z.Logging{ //nolint:exhaustruct
+					Console: z.Console{
+						Type:        "nocolor",
+						Level:       zerolog.TraceLevel,
+						Target:      "stdout",
+						Output:      &buffer,
+						ErrorOutput: nil,
+					},
+					Main: z.Main{
+						Level:        zerolog.Disabled,
+						Slog:         false,
+						StdlogLevel:  nil,
+						StdlogCaller: false,
+					},
+					Context: z.Context{
+						Level:             zerolog.TraceLevel,
+						ConditionalLevel:  zerolog.DebugLevel,
+						TriggerLevel:      zerolog.ErrorLevel,
+						MaxEntries:        0,
+						MaxBytes:          0,
+						Summary:           tt.Summary,
+						SummaryLevel:      zerolog.WarnLevel,
+						TriggerOnDeadline: false,
+						TriggerOnDone:     nil,
+						Triggers:          nil,
+					},
+				},
+			}
+			closer, errE := z.New(&config)
+			require.NoError(t, errE, "% -+#.1v", errE)
+			t.Cleanup(func() {
+				_ = closer.Close()
+			})
+
+			ctx, closeCtx, _ := config.WithContext(context.Background())
+			tt.Log(zerolog.Ctx(ctx))
+			closeCtx()
+			// Closing again does not log the summary again.
+			closeCtx()
+			assert.Regexp(t, tt.Expected, buffer.String())
+		})
+	}
+}
+
+func TestWithContextTriggerOnDone(t *testing.T) {
+	errTest := errors.New("test")
+
+	for _, tt := range []struct {
+		Name              string
+		TriggerOnDeadline bool
+		TriggerOnDone     func(cause error) bool
+		Context           func() (context.Context, context.CancelFunc)
+		Flushed           bool
+	}{
+		{
+			Name:              "deadline",
+			TriggerOnDeadline: true,
+			TriggerOnDone:     nil,
+			Context: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), time.Millisecond)
+			},
+			Flushed: true,
+		},
+		{
+			Name:              "deadline not configured",
+			TriggerOnDeadline: false,
+			TriggerOnDone:     nil,
+			Context: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), time.Millisecond)
+			},
+			Flushed: false,
+		},
+		{
+			Name:              "canceled",
+			TriggerOnDeadline: true,
+			TriggerOnDone:     nil,
+			Context: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx, cancel
+			},
+			Flushed: false,
+		},
+		{
+			Name:              "predicate",
+			TriggerOnDeadline: true,
+			TriggerOnDone: func(cause error) bool {
+				return errors.Is(cause, errTest)
+			},
+			Context: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancelCause(context.Background())
+				cancel(errTest)
+				return ctx, func() {}
+			},
+			Flushed: true,
+		},
+		{
+			Name:              "predicate not matching",
+			TriggerOnDeadline: false,
+			TriggerOnDone: func(cause error) bool {
+				return errors.Is(cause, errTest)
+			},
+			Context: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), time.Millisecond)
+			},
+			Flushed: false,
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			config := z.LoggingConfig{ //nolint:exhaustruct
+				Logging: z.Logging{ //nolint:exhaustruct
+					Console: z.Console{
+						Type:        "nocolor",
+						Level:       zerolog.TraceLevel,
+						Target:      "stdout",
+						Output:      &buffer,
+						ErrorOutput: nil,
+					},
+					Main: z.Main{
+						Level:        zerolog.Disabled,
+						Slog:         false,
+						StdlogLevel:  nil,
+						StdlogCaller: false,
+					},
+					Context: z.Context{
+						Level:             zerolog.TraceLevel,
+						ConditionalLevel:  zerolog.DebugLevel,
+						TriggerLevel:      zerolog.ErrorLevel,
+						MaxEntries:        0,
+						MaxBytes:          0,
+						Summary:           false,
+						SummaryLevel:      zerolog.InfoLevel,
+						TriggerOnDeadline: tt.TriggerOnDeadline,
+						TriggerOnDone:     tt.TriggerOnDone,
+						Triggers:          nil,
+					},
+				},
+			}
+			closer, errE := z.New(&config)
+			require.NoError(t, errE, "% -+#.1v", errE)
+			t.Cleanup(func() {
+				_ = closer.Close()
+			})
+
+			ctx, cancel := tt.Context()
+			defer cancel()
+
+			ctx, closeCtx, _ := config.WithContext(ctx)
+			zerolog.Ctx(ctx).Debug().Msg("buffered")
+			<-ctx.Done()
+			closeCtx()
+
+			if tt.Flushed {
+				assert.Regexp(t, `^\d{2}:\d{2} DBG buffered\n$`, buffer.String())
+			} else {
+				assert.Empty(t, buffer.String())
+			}
+		})
+	}
+}
+
+func TestWithContextTriggers(t *testing.T) {
+	for _, tt := range []struct {
+		Name     string
+		Triggers []z.TriggerFunc
+		Log      func(logger *zerolog.Logger)
+		Expected string
+	}{
+		{
+			Name:     "field",
+			Triggers: []z.TriggerFunc{z.TriggerOnField(zerolog.ErrorFieldName)},
+			Log: func(logger *zerolog.Logger) {
+				logger.Debug().Msg("a")
+				logger.Debug().Str("error", "boom").Msg("b")
+				logger.Debug().Msg("c")
+			},
+			Expected: `^\d{2}:\d{2} DBG a\n\d{2}:\d{2} DBG b error=boom\n\d{2}:\d{2} DBG c\n$`,
+		},
+		{
+			Name:     "field value",
+			Triggers: []z.TriggerFunc{z.TriggerOnFieldValue("status", http.StatusInternalServerError)},
+			Log: func(logger *zerolog.Logger) {
+				logger.Debug().Msg("a")
+				logger.Debug().Int("status", http.StatusNotFound).Msg("b")
+				logger.Debug().Int("status", http.StatusInternalServerError).Msg("c")
+			},
+			Expected: `^\d{2}:\d{2} DBG a\n\d{2}:\d{2} DBG b status=404\n\d{2}:\d{2} DBG c status=500\n$`,
+		},
+		{
+			Name:     "field value not matching",
+			Triggers: []z.TriggerFunc{z.TriggerOnFieldValue("status", http.StatusInternalServerError)},
+			Log: func(logger *zerolog.Logger) {
+				logger.Debug().Msg("a")
+				logger.Debug().Str("status", "500").Msg("b")
+			},
+			Expected: `^$`,
+		},
+		{
+			Name:     "count",
+			Triggers: []z.TriggerFunc{z.TriggerOnCount(zerolog.WarnLevel, 2)},
+			Log: func(logger *zerolog.Logger) {
+				logger.Debug().Msg("a")
+				logger.Warn().Msg("b")
+				logger.Debug().Msg("c")
+				logger.Warn().Msg("d")
+			},
+			Expected: `^\d{2}:\d{2} WRN b\n\d{2}:\d{2} DBG a\n\d{2}:\d{2} DBG c\n\d{2}:\d{2} WRN d\n$`,
+		},
+		{
+			Name:     "count not reached",
+			Triggers: []z.TriggerFunc{z.TriggerOnCount(zerolog.WarnLevel, 2)},
+			Log: func(logger *zerolog.Logger) {
+				logger.Debug().Msg("a")
+				logger.Warn().Msg("b")
+				logger.Debug().Msg("c")
+			},
+			Expected: `^\d{2}:\d{2} WRN b\n$`,
+		},
+		{
+			Name: "custom",
+			Triggers: []z.TriggerFunc{func(entry z.TriggerEntry) bool {
+				return entry.Level == zerolog.TraceLevel
+			}},
+			Log: func(logger *zerolog.Logger) {
+				logger.Debug().Msg("a")
+				logger.Trace().Msg("b")
+			},
+			Expected: `^\d{2}:\d{2} DBG a\n\d{2}:\d{2} TRC b\n$`,
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			config := z.LoggingConfig{ //nolint:exhaustruct
+				Logging: z.Logging{ //nolint:exhaustruct
+					Console: z.Console{
+						Type:        "nocolor",
+						Level:       zerolog.TraceLevel,
+						Target:      "stdout",
+						Output:      &buffer,
+						ErrorOutput: nil,
+					},
+					Main: z.Main{
+						Level:        zerolog.Disabled,
+						Slog:         false,
+						StdlogLevel:  nil,
+						StdlogCaller: false,
+					},
+					Context: z.Context{
+						Level:             zerolog.TraceLevel,
+						ConditionalLevel:  zerolog.DebugLevel,
+						TriggerLevel:      zerolog.ErrorLevel,
+						MaxEntries:        0,
+						MaxBytes:          0,
+						Summary:           false,
+						SummaryLevel:      zerolog.InfoLevel,
+						TriggerOnDeadline: false,
+						TriggerOnDone:     nil,
+						Triggers:          tt.Triggers,
+					},
+				},
+			}
+			closer, errE := z.New(&config)
+			require.NoError(t, errE, "% -+#.1v", errE)
+			t.Cleanup(func() {
+				_ = closer.Close()
+			})
+
+			ctx, closeCtx, _ := config.WithContext(context.Background())
+			tt.Log(zerolog.Ctx(ctx))
+			closeCtx()
+			assert.Regexp(t, tt.Expected, buffer.String())
+		})
+	}
+}
+
+func TestWithContextNested(t *testing.T) {
+	for _, tt := range []struct {
+		Name     string
+		Log      func(t *testing.T, withContext z.WithContextFunc)
+		Expected string
+	}{
+		{
+			Name: "child triggers",
+			Log: func(t *testing.T, withContext z.WithContextFunc) {
+				t.Helper()
+
+				ctx, closeCtx, _ := withContext(context.Background())
+				defer closeCtx()
+				zerolog.Ctx(ctx).UpdateContext(func(c zerolog.Context) zerolog.Context {
+					return c.Str("request", "1")
+				})
+				zerolog.Ctx(ctx).Debug().Msg("a")
+
+				childCtx, closeChildCtx, _ := withContext(ctx)
+				zerolog.Ctx(childCtx).UpdateContext(func(c zerolog.Context) zerolog.Context {
+					return c.Str("op", "x")
+				})
+				zerolog.Ctx(childCtx).Debug().Msg("b")
+				zerolog.Ctx(ctx).Debug().Msg("c")
+				zerolog.Ctx(childCtx).Error().Msg("d")
+				closeChildCtx()
+
+				zerolog.Ctx(ctx).Debug().Msg("e")
+			},
+			Expected: `^\d{2}:\d{2} DBG a request=1\n` +
+				`\d{2}:\d{2} DBG b op=x request=1\n` +
+				`\d{2}:\d{2} DBG c request=1\n` +
+				`\d{2}:\d{2} ERR d op=x request=1\n` +
+				`\d{2}:\d{2} DBG e request=1\n$`,
+		},
+		{
+			Name: "parent triggers",
+			Log: func(t *testing.T, withContext z.WithContextFunc) {
+				t.Helper()
+
+				ctx, closeCtx, trigger := withContext(context.Background())
+				defer closeCtx()
+				zerolog.Ctx(ctx).Debug().Msg("a")
+
+				childCtx, closeChildCtx, _ := withContext(ctx)
+				zerolog.Ctx(childCtx).Debug().Msg("b")
+				// Closing the nested logger does not discard buffered entries.
+				closeChildCtx()
+
+				trigger()
+			},
+			Expected: `^\d{2}:\d{2} DBG a\n\d{2}:\d{2} DBG b\n$`,
+		},
+		{
+			Name: "not triggered",
+			Log: func(t *testing.T, withContext z.WithContextFunc) {
+				t.Helper()
+
+				ctx, closeCtx, _ := withContext(context.Background())
+				zerolog.Ctx(ctx).Debug().Msg("a")
+
+				childCtx, closeChildCtx, _ := withContext(ctx)
+				zerolog.Ctx(childCtx).Debug().Msg("b")
+				closeChildCtx()
+				closeCtx()
+			},
+			Expected: `^$`,
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			config := z.LoggingConfig{ //nolint:exhaustruct
+				Logging: z.Logging{ //nolint:exhaustruct
+					Console: z.Console{
+						Type:        "nocolor",
+						Level:       zerolog.TraceLevel,
+						Target:      "stdout",
+						Output:      &buffer,
+						ErrorOutput: nil,
+					},
+					Main: z.Main{
+						Level:        zerolog.Disabled,
+						Slog:         false,
+						StdlogLevel:  nil,
+						StdlogCaller: false,
+					},
+					Context: z.Context{
+						Level:             zerolog.TraceLevel,
+						ConditionalLevel:  zerolog.DebugLevel,
+						TriggerLevel:      zerolog.ErrorLevel,
+						MaxEntries:        0,
+						MaxBytes:          0,
+						Summary:           false,
+						SummaryLevel:      zerolog.InfoLevel,
+						TriggerOnDeadline: false,
+						TriggerOnDone:     nil,
+						Triggers:          nil,
+					},
+				},
+			}
+			closer, errE := z.New(&config)
+			require.NoError(t, errE, "% -+#.1v", errE)
+			t.Cleanup(func() {
+				_ = closer.Close()
+			})
+
+			tt.Log(t, config.WithContext)
+			assert.Regexp(t, tt.Expected, buffer.String())
+		})
+	}
+}
+
 func TestNewHandler(t *testing.T) {
 	for k, tt := range []struct {
 		Code    int
@@ -760,21 +1340,33 @@ func TestNewHandler(t *testing.T) {
 				WithContext: nil,
 				Logging: z.Logging{
 					Console: z.Console{
-						Type:   "nocolor",
-						Level:  zerolog.DebugLevel,
-						Output: buffer,
+						Type:        "nocolor",
+						Level:       zerolog.DebugLevel,
+						Target:      "stdout",
+						Output:      buffer,
+						ErrorOutput: nil,
 					},
 					File: z.File{
 						Level: zerolog.Disabled,
 						Path:  "",
 					},
 					Main: z.Main{
-						Level: zerolog.Disabled,
+						Level:        zerolog.Disabled,
+						Slog:         false,
+						StdlogLevel:  nil,
+						StdlogCaller: false,
 					},
 					Context: z.Context{
-						Level:            zerolog.DebugLevel,
-						ConditionalLevel: zerolog.DebugLevel,
-						TriggerLevel:     zerolog.ErrorLevel,
+						Level:             zerolog.DebugLevel,
+						ConditionalLevel:  zerolog.DebugLevel,
+						TriggerLevel:      zerolog.ErrorLevel,
+						MaxEntries:        0,
+						MaxBytes:          0,
+						Summary:           false,
+						SummaryLevel:      zerolog.InfoLevel,
+						TriggerOnDeadline: false,
+						TriggerOnDone:     nil,
+						Triggers:          nil,
 					},
 				},
 			}
@@ -809,6 +1401,576 @@ func TestNewHandler(t *testing.T) {
 	}
 }
 
+func TestNewHandlerDeadline(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	config := z.LoggingConfig{ //nolint:exhaustruct
+		Logging: z.Logging{ //nolint:exhaustruct
+			Console: z.Console{
+				Type:        "nocolor",
+				Level:       zerolog.DebugLevel,
+				Target:      "stdout",
+				Output:      buffer,
+				ErrorOutput: nil,
+			},
+			Main: z.Main{
+				Level:        zerolog.Disabled,
+				Slog:         false,
+				StdlogLevel:  nil,
+				StdlogCaller: false,
+			},
+			Context: z.Context{
+				Level:             zerolog.DebugLevel,
+				ConditionalLevel:  zerolog.DebugLevel,
+				TriggerLevel:      zerolog.ErrorLevel,
+				MaxEntries:        0,
+				MaxBytes:          0,
+				Summary:           false,
+				SummaryLevel:      zerolog.InfoLevel,
+				TriggerOnDeadline: true,
+				TriggerOnDone:     nil,
+				Triggers:          nil,
+			},
+		},
+	}
+	closer, errE := z.New(&config)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	t.Cleanup(func() {
+		_ = closer.Close()
+	})
+
+	h := z.NewHandler(config.WithContext)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		zerolog.Ctx(req.Context()).Debug().Msg("buffered debug")
+		// A slow request which responds successfully after its deadline has been exceeded.
+		<-req.Context().Done()
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Regexp(t, `^\d{2}:\d{2} DBG buffered debug\n$`, buffer.String())
+}
+
+func TestNewHandlerAccessLog(t *testing.T) {
+	for k, tt := range []struct {
+		Code     int
+		Expected string
+	}{
+		{http.StatusOK, `^\{"level":"info","method":"GET","path":"/items/42","route":"GET /items/\{id\}","status":200,"size":4,"duration":[0-9.]+,"remote":"192.0.2.1:1234","userAgent":"test","time":"[^"]+","message":"request"\}\n$`},
+		{http.StatusNotFound, `^\{"level":"warn","method":"GET","path":"/items/42","route":"GET /items/\{id\}","status":404,"size":4,"duration":[0-9.]+,"remote":"192.0.2.1:1234","userAgent":"test","time":"[^"]+","message":"request"\}\n$`},
+		{http.StatusInternalServerError, `^\{"level":"debug","time":"[^"]+","message":"buffered debug"\}\n\{"level":"error","method":"GET","path":"/items/42","route":"GET /items/\{id\}","status":500,"size":4,"duration":[0-9.]+,"remote":"192.0.2.1:1234","userAgent":"test","time":"[^"]+","message":"request"\}\n$`},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			buffer := new(bytes.Buffer)
+			config := z.LoggingConfig{ //nolint:exhaustruct
+				Logging: z.Logging{ //nolint:exhaustruct
+					Console: z.Console{
+						Type:        "json",
+						Level:       zerolog.DebugLevel,
+						Target:      "stdout",
+						Output:      buffer,
+						ErrorOutput: nil,
+					},
+					Main: z.Main{
+						Level:        zerolog.Disabled,
+						Slog:         false,
+						StdlogLevel:  nil,
+						StdlogCaller: false,
+					},
+					Context: z.Context{
+						Level:             zerolog.DebugLevel,
+						ConditionalLevel:  zerolog.DebugLevel,
+						TriggerLevel:      zerolog.ErrorLevel,
+						MaxEntries:        0,
+						MaxBytes:          0,
+						Summary:           false,
+						SummaryLevel:      zerolog.InfoLevel,
+						TriggerOnDeadline: false,
+						TriggerOnDone:     nil,
+						Triggers:          nil,
+					},
+				},
+			}
+			_, errE := z.New(&config)
+			require.NoError(t, errE, "% -+#.1v", errE)
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, req *http.Request) {
+				zerolog.Ctx(req.Context()).Debug().Msg("buffered debug")
+				w.WriteHeader(tt.Code)
+				_, err := w.Write([]byte("body"))
+				assert.NoError(t, err)
+			})
+			h := z.NewHandler(config.WithContext, z.WithAccessLog())(mux)
+
+			req := httptest.NewRequest(http.MethodGet, "/items/42", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			req.Header.Set("User-Agent", "test")
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tt.Code, recorder.Code)
+			assert.Regexp(t, tt.Expected, buffer.String())
+		})
+	}
+}
+
+func TestNewHandlerRequestID(t *testing.T) {
+	for k, tt := range []struct {
+		Header   string
+		Incoming string
+		Expected string
+	}{
+		{"", "", `^[A-Z2-7]{26}$`},
+		{"", "abc-123", `^abc-123$`},
+		{"", "invalid id", `^[A-Z2-7]{26}$`},
+		{"", strings.Repeat("x", 200), `^[A-Z2-7]{26}$`},
+		{"X-Correlation-ID", "abc-123", `^abc-123$`},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			buffer := new(bytes.Buffer)
+			config := z.LoggingConfig{ //nolint:exhaustruct
+				Logging: z.Logging{ //nolint:exhaustruct
+					Console: z.Console{
+						Type:        "json",
+						Level:       zerolog.DebugLevel,
+						Target:      "stdout",
+						Output:      buffer,
+						ErrorOutput: nil,
+					},
+					Main: z.Main{
+						Level:        zerolog.Disabled,
+						Slog:         false,
+						StdlogLevel:  nil,
+						StdlogCaller: false,
+					},
+					Context: z.Context{
+						Level:             zerolog.DebugLevel,
+						ConditionalLevel:  zerolog.DebugLevel,
+						TriggerLevel:      zerolog.ErrorLevel,
+						MaxEntries:        0,
+						MaxBytes:          0,
+						Summary:           false,
+						SummaryLevel:      zerolog.InfoLevel,
+						TriggerOnDeadline: false,
+						TriggerOnDone:     nil,
+						Triggers:          nil,
+					},
+				},
+			}
+			_, errE := z.New(&config)
+			require.NoError(t, errE, "% -+#.1v", errE)
+
+			header := tt.Header
+			if header == "" {
+				header = z.DefaultRequestIDHeader
+			}
+
+			var id string
+			handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				var ok bool
+				id, ok = z.RequestID(req.Context())
+				assert.True(t, ok)
+				zerolog.Ctx(req.Context()).Debug().Msg("buffered debug")
+				w.WriteHeader(http.StatusInternalServerError)
+			})
+			// Nesting NewHandler does not change the request ID.
+			h := z.NewHandler(config.WithContext, z.WithRequestID(tt.Header))(z.NewHandler(config.WithContext, z.WithRequestID(tt.Header))(handler))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.Incoming != "" {
+				req.Header.Set(header, tt.Incoming)
+			}
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, req)
+
+			assert.Regexp(t, tt.Expected, id)
+			assert.Equal(t, id, recorder.Header().Get(header))
+			assert.Regexp(t, `^\{"level":"debug","requestId":"`+regexp.QuoteMeta(id)+`","time":"[^"]+","message":"buffered debug"\}\n$`, buffer.String())
+		})
+	}
+}
+
+func TestNewHandlerContextFields(t *testing.T) {
+	for _, tt := range []struct {
+		Status   int
+		Messages []string
+	}{
+		{http.StatusInternalServerError, []string{"buffered log entries dropped", "second"}},
+		{http.StatusOK, []string{"buffered log entries discarded"}},
+	} {
+		t.Run(strconv.Itoa(tt.Status), func(t *testing.T) {
+			buffer := new(bytes.Buffer)
+			config := z.LoggingConfig{ //nolint:exhaustruct
+				Logging: z.Logging{ //nolint:exhaustruct
+					Console: z.Console{
+						Type:        "json",
+						Level:       zerolog.DebugLevel,
+						Target:      "stdout",
+						Output:      buffer,
+						ErrorOutput: nil,
+					},
+					Main: z.Main{
+						Level:        zerolog.Disabled,
+						Slog:         false,
+						StdlogLevel:  nil,
+						StdlogCaller: false,
+					},
+					Context: z.Context{
+						Level:             zerolog.DebugLevel,
+						ConditionalLevel:  zerolog.DebugLevel,
+						TriggerLevel:      zerolog.ErrorLevel,
+						MaxEntries:        1,
+						MaxBytes:          0,
+						Summary:           true,
+						SummaryLevel:      zerolog.InfoLevel,
+						TriggerOnDeadline: false,
+						TriggerOnDone:     nil,
+						Triggers:          nil,
+					},
+				},
+			}
+			_, errE := z.New(&config)
+			require.NoError(t, errE, "% -+#.1v", errE)
+
+			var id string
+			var trace z.TraceContext
+			handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				id, _ = z.RequestID(req.Context())
+				trace, _ = z.Trace(req.Context())
+				zerolog.Ctx(req.Context()).Debug().Msg("first")
+				zerolog.Ctx(req.Context()).Debug().Msg("second")
+				w.WriteHeader(tt.Status)
+			})
+			h := z.NewHandler(config.WithContext, z.WithRequestID(""), z.WithTraceContext())(handler)
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+			// Log entries written by the context logger's writer itself have its fields, too.
+			lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+			require.Len(t, lines, len(tt.Messages))
+			for i, line := range lines {
+				var entry map[string]interface{}
+				require.NoError(t, json.Unmarshal([]byte(line), &entry))
+				assert.Equal(t, tt.Messages[i], entry["message"])
+				assert.Equal(t, id, entry["requestId"])
+				assert.Equal(t, trace.TraceID, entry["trace_id"])
+				assert.Equal(t, trace.SpanID, entry["span_id"])
+			}
+		})
+	}
+}
+
+func TestNewHandlerRecover(t *testing.T) {
+	for k, tt := range []struct {
+		Handler func(w http.ResponseWriter)
+		Code    int
+		Error   map[string]interface{}
+		Level   string
+	}{
+		{
+			func(_ http.ResponseWriter) {
+				panic("boom")
+			},
+			http.StatusInternalServerError,
+			map[string]interface{}{"error": "panic", "value": "boom"},
+			"error",
+		},
+		{
+			func(_ http.ResponseWriter) {
+				panic(io.ErrUnexpectedEOF)
+			},
+			http.StatusInternalServerError,
+			map[string]interface{}{"error": "panic", "cause": map[string]interface{}{"error": "unexpected EOF"}},
+			"error",
+		},
+		{
+			func(w http.ResponseWriter) {
+				w.WriteHeader(http.StatusAccepted)
+				panic("boom")
+			},
+			http.StatusAccepted,
+			map[string]interface{}{"error": "panic", "value": "boom"},
+			"info",
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			buffer := new(bytes.Buffer)
+			config := z.LoggingConfig{ //nolint:exhaustruct
+				Logging: z.Logging{ //nolint:exhaustruct
+					Console: z.Console{
+						Type:        "json",
+						Level:       zerolog.DebugLevel,
+						Target:      "stdout",
+						Output:      buffer,
+						ErrorOutput: nil,
+					},
+					Main: z.Main{
+						Level:        zerolog.Disabled,
+						Slog:         false,
+						StdlogLevel:  nil,
+						StdlogCaller: false,
+					},
+					Context: z.Context{
+						Level:             zerolog.DebugLevel,
+						ConditionalLevel:  zerolog.DebugLevel,
+						TriggerLevel:      zerolog.ErrorLevel,
+						MaxEntries:        0,
+						MaxBytes:          0,
+						Summary:           false,
+						SummaryLevel:      zerolog.InfoLevel,
+						TriggerOnDeadline: false,
+						TriggerOnDone:     nil,
+						Triggers:          nil,
+					},
+				},
+			}
+			_, errE := z.New(&config)
+			require.NoError(t, errE, "% -+#.1v", errE)
+
+			h := z.NewHandler(config.WithContext, z.WithRecover(), z.WithAccessLog())(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				zerolog.Ctx(req.Context()).Debug().Msg("buffered debug")
+				tt.Handler(w)
+			}))
+
+			recorder := httptest.NewRecorder()
+			assert.NotPanics(t, func() {
+				h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+			})
+			assert.Equal(t, tt.Code, recorder.Code)
+
+			entries := []map[string]interface{}{}
+			for _, line := range strings.Split(strings.TrimSpace(buffer.String()), "\n") {
+				var entry map[string]interface{}
+				require.NoError(t, json.Unmarshal([]byte(line), &entry))
+				entries = append(entries, entry)
+			}
+			require.Len(t, entries, 3)
+
+			assert.Equal(t, "buffered debug", entries[0]["message"])
+
+			assert.Equal(t, "error", entries[1]["level"])
+			assert.Equal(t, "recovered from panic", entries[1]["message"])
+			e, ok := entries[1]["error"].(map[string]interface{})
+			require.True(t, ok)
+			stack, ok := e["stack"].([]interface{})
+			require.True(t, ok)
+			delete(e, "stack")
+			assert.Equal(t, tt.Error, e)
+			// The stack trace includes where the panic happened.
+			assert.Contains(t, fmt.Sprint(stack), "zerolog_test.TestNewHandlerRecover.func")
+
+			assert.Equal(t, tt.Level, entries[2]["level"])
+			assert.Equal(t, "request", entries[2]["message"])
+			assert.InDelta(t, tt.Code, entries[2]["status"], 0)
+		})
+	}
+}
+
+func TestNewHandlerRecoverAbort(t *testing.T) {
+	config := z.LoggingConfig{ //nolint:exhaustruct
+		Logging: z.Logging{ //nolint:exhaustruct
+			Console: z.Console{
+				Type:        "json",
+				Level:       zerolog.DebugLevel,
+				Target:      "stdout",
+				Output:      io.Discard,
+				ErrorOutput: nil,
+			},
+			Main: z.Main{
+				Level:        zerolog.Disabled,
+				Slog:         false,
+				StdlogLevel:  nil,
+				StdlogCaller: false,
+			},
+			Context: z.Context{
+				Level:             zerolog.DebugLevel,
+				ConditionalLevel:  zerolog.DebugLevel,
+				TriggerLevel:      zerolog.ErrorLevel,
+				MaxEntries:        0,
+				MaxBytes:          0,
+				Summary:           false,
+				SummaryLevel:      zerolog.InfoLevel,
+				TriggerOnDeadline: false,
+				TriggerOnDone:     nil,
+				Triggers:          nil,
+			},
+		},
+	}
+	_, errE := z.New(&config)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	h := z.NewHandler(config.WithContext, z.WithRecover())(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
+
+func TestNewHandlerTriggerPolicy(t *testing.T) {
+	for k, tt := range []struct {
+		Policy  z.TriggerPolicy
+		Code    int
+		Body    int
+		Sleep   time.Duration
+		Flushed bool
+	}{
+		// The zero policy keeps the default behavior.
+		{z.TriggerPolicy{}, http.StatusInternalServerError, 0, 0, true},                                               //nolint:exhaustruct
+		{z.TriggerPolicy{}, http.StatusUnauthorized, 0, 0, false},                                                     //nolint:exhaustruct
+		{z.TriggerPolicy{Statuses: []int{http.StatusUnauthorized}}, http.StatusUnauthorized, 0, 0, true},              //nolint:exhaustruct
+		{z.TriggerPolicy{Statuses: []int{http.StatusUnauthorized}}, http.StatusForbidden, 0, 0, false},                //nolint:exhaustruct
+		{z.TriggerPolicy{Statuses: []int{http.StatusUnauthorized}}, http.StatusBadGateway, 0, 0, false},               //nolint:exhaustruct
+		{z.TriggerPolicy{Status: func(code int) bool { return code >= 400 }}, http.StatusTooManyRequests, 0, 0, true}, //nolint:exhaustruct
+		{z.TriggerPolicy{Latency: 10 * time.Millisecond}, http.StatusOK, 0, 0, false},                                 //nolint:exhaustruct
+		{z.TriggerPolicy{Latency: 10 * time.Millisecond}, http.StatusOK, 0, 50 * time.Millisecond, true},              //nolint:exhaustruct
+		{z.TriggerPolicy{Size: func(size int64) bool { return size > 10 }}, http.StatusOK, 5, 0, false},               //nolint:exhaustruct
+		{z.TriggerPolicy{Size: func(size int64) bool { return size > 10 }}, http.StatusOK, 20, 0, true},               //nolint:exhaustruct
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			buffer := new(bytes.Buffer)
+			config := z.LoggingConfig{ //nolint:exhaustruct
+				Logging: z.Logging{ //nolint:exhaustruct
+					Console: z.Console{
+						Type:        "nocolor",
+						Level:       zerolog.DebugLevel,
+						Target:      "stdout",
+						Output:      buffer,
+						ErrorOutput: nil,
+					},
+					Main: z.Main{
+						Level:        zerolog.Disabled,
+						Slog:         false,
+						StdlogLevel:  nil,
+						StdlogCaller: false,
+					},
+					Context: z.Context{
+						Level:             zerolog.DebugLevel,
+						ConditionalLevel:  zerolog.DebugLevel,
+						TriggerLevel:      zerolog.ErrorLevel,
+						MaxEntries:        0,
+						MaxBytes:          0,
+						Summary:           false,
+						SummaryLevel:      zerolog.InfoLevel,
+						TriggerOnDeadline: false,
+						TriggerOnDone:     nil,
+						Triggers:          nil,
+					},
+				},
+			}
+			_, errE := z.New(&config)
+			require.NoError(t, errE, "% -+#.1v", errE)
+
+			for _, accessLog := range []bool{false, true} {
+				t.Run(fmt.Sprintf("accessLog=%t", accessLog), func(t *testing.T) {
+					buffer.Reset()
+
+					options := []z.HandlerOption{z.WithTriggerPolicy(tt.Policy)}
+					if accessLog {
+						options = append(options, z.WithAccessLog())
+					}
+					h := z.NewHandler(config.WithContext, options...)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+						zerolog.Ctx(req.Context()).Debug().Msg("buffered debug")
+						time.Sleep(tt.Sleep)
+						w.WriteHeader(tt.Code)
+						_, err := w.Write(bytes.Repeat([]byte("x"), tt.Body))
+						assert.NoError(t, err)
+					}))
+
+					recorder := httptest.NewRecorder()
+					h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+					// The access log entry does not itself trigger.
+					output := buffer.String()
+					if accessLog {
+						assert.Regexp(t, `\d{2}:\d{2} (INF|WRN|ERR) request .*status=`+strconv.Itoa(tt.Code)+` .*\n$`, output)
+						output = output[:strings.LastIndex(strings.TrimSuffix(output, "\n"), "\n")+1]
+					}
+
+					if tt.Flushed {
+						assert.Regexp(t, `^\d{2}:\d{2} DBG buffered debug\n$`, output)
+					} else {
+						assert.Empty(t, output)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestNewHandlerDebugEscalation(t *testing.T) {
+	for k, tt := range []struct {
+		Header   map[string]string
+		Target   string
+		Expected string
+	}{
+		{nil, "/", `^$`},
+		{map[string]string{"X-Debug-Log": "trace", "X-Debug-Secret": "secret"}, "/", `^\d{2}:\d{2} TRC trace\n\d{2}:\d{2} DBG debug\n$`},
+		{map[string]string{"X-Debug-Log": "debug", "X-Debug-Secret": "secret"}, "/", `^\d{2}:\d{2} DBG debug\n$`},
+		{map[string]string{"X-Debug-Log": "trace", "X-Debug-Secret": "wrong"}, "/", `^$`},
+		{map[string]string{"X-Debug-Log": "trace"}, "/", `^$`},
+		{map[string]string{"X-Debug-Log": "invalid", "X-Debug-Secret": "secret"}, "/", `^$`},
+		{map[string]string{"X-Debug-Secret": "secret"}, "/?debug=trace", `^\d{2}:\d{2} TRC trace\n\d{2}:\d{2} DBG debug\n$`},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			buffer := new(bytes.Buffer)
+			config := z.LoggingConfig{ //nolint:exhaustruct
+				Logging: z.Logging{ //nolint:exhaustruct
+					Console: z.Console{
+						Type:        "nocolor",
+						Level:       zerolog.TraceLevel,
+						Target:      "stdout",
+						Output:      buffer,
+						ErrorOutput: nil,
+					},
+					Main: z.Main{
+						Level:        zerolog.Disabled,
+						Slog:         false,
+						StdlogLevel:  nil,
+						StdlogCaller: false,
+					},
+					Context: z.Context{
+						Level:             zerolog.DebugLevel,
+						ConditionalLevel:  zerolog.DebugLevel,
+						TriggerLevel:      zerolog.ErrorLevel,
+						MaxEntries:        0,
+						MaxBytes:          0,
+						Summary:           false,
+						SummaryLevel:      zerolog.InfoLevel,
+						TriggerOnDeadline: false,
+						TriggerOnDone:     nil,
+						Triggers:          nil,
+					},
+				},
+			}
+			_, errE := z.New(&config)
+			require.NoError(t, errE, "% -+#.1v", errE)
+
+			h := z.NewHandler(config.WithContext, z.WithDebugEscalation(z.DebugEscalation{
+				Header:    "",
+				Query:     "debug",
+				Authorize: z.SharedSecret("X-Debug-Secret", "secret"),
+			}))(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+				zerolog.Ctx(req.Context()).Trace().Msg("trace")
+				zerolog.Ctx(req.Context()).Debug().Msg("debug")
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, tt.Target, nil)
+			for key, value := range tt.Header {
+				req.Header.Set(key, value)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Regexp(t, tt.Expected, buffer.String())
+		})
+	}
+}
+
 type kongConfig struct {
 	z.LoggingConfig
 }
@@ -827,11 +1989,15 @@ func createKong(t *testing.T, expectExit bool, args []string) (kongConfig, bytes
 		kong.Vars{
 			"defaultLoggingConsoleType":             z.DefaultConsoleType,
 			"defaultLoggingConsoleLevel":            z.DefaultConsoleLevel,
+			"defaultLoggingConsoleTarget":           z.DefaultConsoleTarget,
 			"defaultLoggingFileLevel":               z.DefaultFileLevel,
+			"defaultLoggingSyslogLevel":             z.DefaultSyslogLevel,
+			"defaultLoggingJournaldLevel":           z.DefaultJournaldLevel,
 			"defaultLoggingMainLevel":               z.DefaultMainLevel,
 			"defaultLoggingContextLevel":            z.DefaultContextLevel,
 			"defaultLoggingContextConditionalLevel": z.DefaultContextConditionalLevel,
 			"defaultLoggingContextTriggerLevel":     z.DefaultContextTriggerLevel,
+			"defaultLoggingContextSummaryLevel":     z.DefaultContextSummaryLevel,
 		},
 		z.KongLevelTypeMapper,
 		kong.ValueFormatter(cli.DefaultValueFormatter),
@@ -851,46 +2017,423 @@ func TestKong(t *testing.T) {
 	config, buffer, ctx, err := createKong(t, false, []string{"--logging.console.type=nocolor"})
 	require.NoError(t, err)
 	config.Logging.Console.Output = &buffer
-	logFile, errE := z.New(&config)
-	defer logFile.Close() //nolint:errcheck
+	closer, errE := z.New(&config)
+	defer closer.Close() //nolint:errcheck
 	require.NoError(t, errE)
 	config.Logger.Info().Msgf("%s running", ctx.Model.Name)
 	assert.Regexp(t, `\d{2}:\d{2} INF zerolog.test running\n`, buffer.String())
 }
 
+func TestKongFiles(t *testing.T) {
+	config, _, _, err := createKong(t, false, []string{
+		"--logging.file.path=main.log",
+		"--logging.files=errors.log,level=error,mode=0640",
+		"--logging.files=debug.log,level=trace,format=nocolor,maxSize=1024,compress",
+		`--logging.files={"path":"json.log","level":"warn","rotate":"daily"}`,
+	})
+	require.NoError(t, err)
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(wd, "main.log"), config.Logging.File.Path)
+	// Paths are expanded in the same way as for the file flag.
+	assert.Equal(t, []z.File{
+		{Path: filepath.Join(wd, "errors.log"), Level: zerolog.ErrorLevel, Mode: 0o640},                                     //nolint:exhaustruct
+		{Path: filepath.Join(wd, "debug.log"), Level: zerolog.TraceLevel, Format: "nocolor", MaxSize: 1024, Compress: true}, //nolint:exhaustruct
+		{Path: filepath.Join(wd, "json.log"), Level: zerolog.WarnLevel, Rotate: "daily"},                                    //nolint:exhaustruct
+	}, config.Logging.Files)
+
+	_, _, _, err = createKong(t, true, []string{"--logging.files=errors.log,unknown=value"})
+	assert.Error(t, err)
+
+	_, _, _, err = createKong(t, true, []string{"--logging.file.rotate=weekly"})
+	assert.Error(t, err)
+}
+
+func TestUnmarshalFiles(t *testing.T) {
+	expected := z.Logging{ //nolint:exhaustruct
+		Files: []z.File{
+			{Path: "errors.log", Level: zerolog.ErrorLevel, Mode: 0o640, MaxAge: time.Hour}, //nolint:exhaustruct
+			{Path: "debug.log", Level: zerolog.TraceLevel, Format: "nocolor"},               //nolint:exhaustruct
+		},
+	}
+
+	var fromJSON z.Logging
+	err := json.Unmarshal([]byte(`{"files":[{"path":"errors.log","level":"error","mode":"0640","maxAge":"1h"},{"path":"debug.log","level":"trace","format":"nocolor"}]}`), &fromJSON)
+	require.NoError(t, err)
+	assert.Equal(t, expected, fromJSON)
+
+	var fromYAML z.Logging
+	err = yaml.Unmarshal([]byte(`
+files:
+  - path: errors.log
+    level: error
+    mode: "0640"
+    maxAge: 1h
+  - path: debug.log
+    level: trace
+    format: nocolor
+`), &fromYAML)
+	require.NoError(t, err)
+	assert.Equal(t, expected, fromYAML)
+}
+
+func TestUnmarshalFileMaxAge(t *testing.T) {
+	expected := z.File{Path: "errors.log", MaxAge: time.Hour} //nolint:exhaustruct
+
+	// Marshaled time.Duration is a number of nanoseconds in JSON.
+	for _, value := range []string{`"1h"`, `3600000000000`} {
+		var fromJSON z.File
+		err := json.Unmarshal([]byte(`{"path":"errors.log","maxAge":`+value+`}`), &fromJSON)
+		require.NoError(t, err)
+		assert.Equal(t, expected, fromJSON)
+
+		var fromYAML z.File
+		err = yaml.Unmarshal([]byte("path: errors.log\nmaxAge: "+value+"\n"), &fromYAML)
+		require.NoError(t, err)
+		assert.Equal(t, expected, fromYAML)
+	}
+}
+
+func TestFilesRoundTrip(t *testing.T) {
+	expected := z.Logging{ //nolint:exhaustruct
+		Files: []z.File{
+			{Path: "errors.log", Level: zerolog.ErrorLevel, Mode: 0o640, MaxAge: time.Hour}, //nolint:exhaustruct
+		},
+	}
+
+	// Marshaled os.FileMode is a decimal number.
+	b, err := json.Marshal(expected)
+	require.NoError(t, err)
+	var fromJSON z.Logging
+	err = json.Unmarshal(b, &fromJSON)
+	require.NoError(t, err)
+	assert.Equal(t, expected.Files, fromJSON.Files)
+
+	b, err = yaml.Marshal(expected)
+	require.NoError(t, err)
+	var fromYAML z.Logging
+	err = yaml.Unmarshal(b, &fromYAML)
+	require.NoError(t, err)
+	assert.Equal(t, expected.Files, fromYAML.Files)
+}
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	errorsPath := filepath.Join(dir, "errors.log")
+	debugPath := filepath.Join(dir, "debug.log")
+
+	config := z.LoggingConfig{
+		Logger:      zerolog.Nop(),
+		WithContext: nil,
+		Logging: z.Logging{
+			Console: z.Console{
+				Type:        "disable",
+				Level:       zerolog.DebugLevel,
+				Target:      "stdout",
+				Output:      nil,
+				ErrorOutput: nil,
+			},
+			File: z.File{ //nolint:exhaustruct
+				Level: zerolog.Disabled,
+				Path:  "",
+			},
+			Files: []z.File{
+				{Path: errorsPath, Level: zerolog.ErrorLevel, Mode: 0o640},      //nolint:exhaustruct
+				{Path: debugPath, Level: zerolog.TraceLevel, Format: "nocolor"}, //nolint:exhaustruct
+			},
+			Main: z.Main{
+				Level:        zerolog.TraceLevel,
+				Slog:         false,
+				StdlogLevel:  nil,
+				StdlogCaller: false,
+			},
+			Context: z.Context{
+				Level:             zerolog.TraceLevel,
+				ConditionalLevel:  zerolog.TraceLevel,
+				TriggerLevel:      zerolog.TraceLevel,
+				MaxEntries:        0,
+				MaxBytes:          0,
+				Summary:           false,
+				SummaryLevel:      zerolog.InfoLevel,
+				TriggerOnDeadline: false,
+				TriggerOnDone:     nil,
+				Triggers:          nil,
+			},
+		},
+	}
+	closer, errE := z.New(&config)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	t.Cleanup(func() {
+		// We might double close but we do not care.
+		_ = closer.Close()
+	})
+
+	config.Logger.Debug().Msg("debug")
+	config.Logger.Error().Msg("error")
+	require.NoError(t, closer.Close())
+
+	content, err := os.ReadFile(filepath.Clean(errorsPath))
+	require.NoError(t, err)
+	expectLog("error", `"error"`)(t, string(content))
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(errorsPath)
+		require.NoError(t, err)
+		// Umask might remove some permissions.
+		assert.Zero(t, info.Mode().Perm()&^0o640)
+	}
+
+	content, err = os.ReadFile(filepath.Clean(debugPath))
+	require.NoError(t, err)
+	assert.Regexp(t, `^\d{2}:\d{2} DBG debug\n\d{2}:\d{2} ERR error\n$`, string(content))
+}
+
+func TestFilesInvalidFormat(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "log")
+
+	config := z.LoggingConfig{ //nolint:exhaustruct
+		Logging: z.Logging{ //nolint:exhaustruct
+			Console: z.Console{ //nolint:exhaustruct
+				Type: "disable",
+			},
+			File: z.File{ //nolint:exhaustruct
+				Path: p,
+			},
+			Files: []z.File{
+				{Path: filepath.Join(dir, "other.log"), Format: "invalid"}, //nolint:exhaustruct
+			},
+		},
+	}
+	_, errE := z.New(&config)
+	assert.EqualError(t, errE, "invalid file logging format")
+}
+
+func TestConsoleAuto(t *testing.T) {
+	for _, tt := range []struct {
+		Name string
+		Env  map[string]string
+	}{
+		{"plain", nil},
+		{"no_color", map[string]string{"NO_COLOR": "1"}},
+		{"journal", map[string]string{"JOURNAL_STREAM": "8:12345"}},
+		{"container", map[string]string{"container": "podman"}},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			for key, value := range tt.Env {
+				t.Setenv(key, value)
+			}
+
+			// Output is not a terminal so log entries are formatted as JSON.
+			var buffer bytes.Buffer
+			config := z.LoggingConfig{ //nolint:exhaustruct
+				Logging: z.Logging{ //nolint:exhaustruct
+					Console: z.Console{
+						Type:        "auto",
+						Level:       zerolog.DebugLevel,
+						Target:      "stdout",
+						Output:      &buffer,
+						ErrorOutput: nil,
+					},
+					Main: z.Main{
+						Level:        zerolog.DebugLevel,
+						Slog:         false,
+						StdlogLevel:  nil,
+						StdlogCaller: false,
+					},
+				},
+			}
+			closer, errE := z.New(&config)
+			require.NoError(t, errE, "% -+#.1v", errE)
+			t.Cleanup(func() {
+				_ = closer.Close()
+			})
+
+			config.Logger.Info().Msg("test")
+			expectLog("info", `"test"`)(t, buffer.String())
+		})
+	}
+}
+
+func TestConsoleTarget(t *testing.T) {
+	for _, tt := range []struct {
+		Target         string
+		ExpectedOutput []string
+		ExpectedError  []string
+	}{
+		{"stdout", []string{"DBG debug", "INF info", "WRN warn", "ERR error"}, []string{}},
+		{"stderr", []string{}, []string{"DBG debug", "INF info", "WRN warn", "ERR error"}},
+		{"split", []string{"DBG debug", "INF info"}, []string{"WRN warn", "ERR error"}},
+	} {
+		t.Run(tt.Target, func(t *testing.T) {
+			var output, errorOutput bytes.Buffer
+			config := z.LoggingConfig{ //nolint:exhaustruct
+				Logging: z.Logging{ //nolint:exhaustruct
+					Console: z.Console{
+						Type:        "nocolor",
+						Level:       zerolog.DebugLevel,
+						Target:      tt.Target,
+						Output:      &output,
+						ErrorOutput: &errorOutput,
+					},
+					Main: z.Main{
+						Level:        zerolog.DebugLevel,
+						Slog:         false,
+						StdlogLevel:  nil,
+						StdlogCaller: false,
+					},
+				},
+			}
+			closer, errE := z.New(&config)
+			require.NoError(t, errE, "% -+#.1v", errE)
+			t.Cleanup(func() {
+				_ = closer.Close()
+			})
+
+			config.Logger.Debug().Msg("debug")
+			config.Logger.Info().Msg("info")
+			config.Logger.Warn().Msg("warn")
+			config.Logger.Error().Msg("error")
+
+			for _, c := range []struct {
+				Expected []string
+				Actual   string
+			}{
+				{tt.ExpectedOutput, output.String()},
+				{tt.ExpectedError, errorOutput.String()},
+			} {
+				lines := []string{}
+				for _, line := range strings.Split(strings.TrimSuffix(c.Actual, "\n"), "\n") {
+					if line != "" {
+						// We remove the timestamp.
+						lines = append(lines, line[strings.Index(line, " ")+1:])
+					}
+				}
+				assert.Equal(t, c.Expected, lines)
+			}
+		})
+	}
+}
+
+func TestConsoleInvalidTarget(t *testing.T) {
+	config := z.LoggingConfig{ //nolint:exhaustruct
+		Logging: z.Logging{ //nolint:exhaustruct
+			Console: z.Console{ //nolint:exhaustruct
+				Type:   "json",
+				Target: "invalid",
+			},
+		},
+	}
+	_, errE := z.New(&config)
+	assert.EqualError(t, errE, "invalid console logging target")
+}
+
 const expectedUsage = `Usage: zerolog.test [flags]
 
 Flags:
-  -h, --help                      Show context-sensitive help.
+  -h, --help                       Show context-sensitive help.
       --logging.console.type=TYPE
-                                  Type of console logging. Possible:
-                                  color,nocolor,json,disable. Default: color.
+                                   Type of console logging. Possible:
+                                   auto,color,nocolor,json,logfmt,disable.
+                                   Default: color.
       --logging.console.level=LEVEL
-                                  Filter out all log entries below the level.
-                                  Possible: trace,debug,info,warn,error.
-                                  Default: debug.
-      --logging.file.path=PATH    Append log entries to a file (as well).
+                                   Filter out all log entries below the level.
+                                   Possible: trace,debug,info,warn,error.
+                                   Default: debug.
+      --logging.console.target=TARGET
+                                   Where to write log entries: stdout, stderr,
+                                   or split (warn and above to stderr).
+                                   Possible: stdout,stderr,split. Default:
+                                   stdout.
+      --logging.file.path=PATH     Append log entries to a file (as well).
       --logging.file.level=LEVEL
-                                  Filter out all log entries below the level.
-                                  Possible: trace,debug,info,warn,error.
-                                  Default: debug.
+                                   Filter out all log entries below the level.
+                                   Possible: trace,debug,info,warn,error.
+                                   Default: debug.
+      --logging.file.format=FORMAT
+                                   Format of log entries: json, color, nocolor,
+                                   or logfmt.
+      --logging.file.mode=MODE     Permission mode used when creating the file.
+      --logging.file.max-size=BYTES
+                                   Rotate the file once it would grow over the
+                                   size in bytes.
+      --logging.file.max-backups=NUMBER
+                                   Keep at most this many rotated files.
+      --logging.file.max-age=DURATION
+                                   Remove rotated files older than the duration.
+      --logging.file.rotate=PERIOD
+                                   Rotate the file also hourly or daily.
+                                   Possible: ,daily,hourly.
+      --logging.file.compress      Compress rotated files with gzip.
+      --logging.file.reopen        Reopen the file on SIGHUP.
+      --logging.files=PATH[,KEY=VALUE...]
+                                   Append log entries to an additional file.
+                                   Can be repeated.
+      --logging.syslog.address=URL
+                                   Send log entries to a syslog server at the
+                                   address URL.
+      --logging.syslog.level=LEVEL
+                                   Filter out all log entries below the level.
+                                   Possible: trace,debug,info,warn,error.
+                                   Default: info.
+      --logging.syslog.facility=FACILITY
+                                   Syslog facility. Possible:
+                                   ,kern,user,mail,daemon,auth,syslog,lpr,news,uucp,cron,authpriv,ftp,local0,local1,local2,local3,local4,local5,local6,local7.
+      --logging.syslog.app-name=NAME
+                                   Application name.
+      --logging.syslog.protocol=PROTOCOL
+                                   Syslog protocol: rfc5424 or rfc3164.
+                                   Possible: ,rfc5424,rfc3164.
+      --logging.syslog.payload=PAYLOAD
+                                   Payload of syslog messages: json or message.
+                                   Possible: ,json,message.
+      --logging.journald.enable    Send log entries to journald.
+      --logging.journald.level=LEVEL
+                                   Filter out all log entries below the level.
+                                   Possible: trace,debug,info,warn,error.
+                                   Default: info.
+      --logging.journald.socket=PATH
+                                   Path to journald's socket.
   -l, --logging.main.level=LEVEL
-                                  Log entries at the level or higher. Possible:
-                                  trace,debug,info,warn,error,disabled.
-                                  Default: info. Environment variable:
-                                  LOGGING_MAIN_LEVEL.
+                                   Log entries at the level or higher. Possible:
+                                   trace,debug,info,warn,error,disabled.
+                                   Default: info. Environment variable:
+                                   LOGGING_MAIN_LEVEL.
+      --logging.main.slog          Set slog's default logger to log through the
+                                   main logger.
+      --logging.main.stdlog-level=LEVEL
+                                   Log entries of Go's standard log package
+                                   without a level prefix at the level. By
+                                   default they do not have a level. Possible:
+                                   trace,debug,info,warn,error.
+      --logging.main.stdlog-caller
+                                   Add caller to log entries of Go's standard
+                                   log package.
       --logging.context.level=LEVEL
-                                  Log entries at the level or higher. Possible:
-                                  trace,debug,info,warn,error,disabled. Default:
-                                  debug.
+                                   Log entries at the level or higher. Possible:
+                                   trace,debug,info,warn,error,disabled.
+                                   Default: debug.
       --logging.context.conditional=LEVEL
-                                  Buffer log entries at the level and
-                                  below until triggered. Possible:
-                                  trace,debug,info,warn,error. Default: debug.
+                                   Buffer log entries at the level and
+                                   below until triggered. Possible:
+                                   trace,debug,info,warn,error. Default: debug.
       --logging.context.trigger=LEVEL
-                                  A log entry at the level or higher triggers.
-                                  Possible: trace,debug,info,warn,error.
-                                  Default: error.
+                                   A log entry at the level or higher triggers.
+                                   Possible: trace,debug,info,warn,error.
+                                   Default: error.
+      --logging.context.max-entries=NUMBER
+                                   Buffer at most this many log entries,
+                                   dropping the oldest ones.
+      --logging.context.max-bytes=BYTES
+                                   Buffer at most this many bytes of log
+                                   entries, dropping the oldest ones.
+      --logging.context.summary    Log the number of discarded log entries when
+                                   closed without being triggered.
+      --logging.context.summary-level=LEVEL
+                                   Log the summary at the level. Possible:
+                                   trace,debug,info,warn,error. Default: info.
+      --logging.context.trigger-on-deadline
+                                   Trigger when the context's deadline is
+                                   exceeded.
 `
 
 func TestKongUsage(t *testing.T) {